@@ -17,17 +17,60 @@ func init() {
 	router.POST("/auth/retoken", api.IsAuthorized(api.GetRefereshToken, true))
 	router.GET("/auth/logout", api.Logout)
 	router.POST("/auth/logout", api.Logout)
-	router.GET("/auth/permissions", api.GetPermissions)
+	router.GET("/auth/permissions", api.IsAuthorized(api.GetPermissions, true))
+
+	router.GET("/auth/oidc", api.GetOIDCInfo)
+	router.GET("/auth/oidc/login", api.GetOIDCLogin)
+	router.GET("/auth/oidc/callback", api.GetOIDCCallback)
+
+	router.GET("/auth/keys", api.IsAuthorized(api.GetAPIKeys, true))
+	router.POST("/auth/keys", api.IsAuthorized(api.PostAPIKeys, true))
+	router.DELETE("/auth/keys/:key_id", api.IsAuthorized(api.DeleteAPIKey, true))
+
+	router.GET("/auth/sessions", api.IsAuthorized(api.GetSessions, true))
+	router.DELETE("/auth/sessions", api.IsAuthorized(api.DeleteSessions, true))
+	router.DELETE("/auth/sessions/:session_id", api.IsAuthorized(api.DeleteSession, true))
+
+	router.POST("/auth/totp", api.IsAuthorized(api.PostTOTP, true))
+	router.POST("/auth/totp/confirm", api.IsAuthorized(api.PostTOTPConfirm, true))
+	router.DELETE("/auth/totp", api.IsAuthorized(api.DeleteTOTP, true))
 
 	router.GET("/auth/profile", api.IsAuthorized(api.GetUserProfile, true))
 	router.POST("/auth/profile", api.IsAuthorized(api.PostUserProfile, true))
 
+	// Users
+
+	router.GET("/users", api.IsAuthorized(api.GetUsers, true))
+	router.POST("/users", api.IsAuthorized(api.PostUsers, true))
+	router.GET("/users/:user_id", api.IsAuthorized(api.GetUser, true))
+	router.POST("/users/:user_id", api.IsAuthorized(api.PostUser, true))
+	router.DELETE("/users/:user_id", api.IsAuthorized(api.DeleteUser, true))
+	router.DELETE("/users/:user_id/totp", api.IsAuthorized(api.DeleteUserTOTP, true))
+
+	router.GET("/lockouts", api.IsAuthorized(api.GetLockouts, true))
+	router.DELETE("/lockouts", api.IsAuthorized(api.DeleteLockouts, true))
+	router.DELETE("/lockouts/:lockout_id", api.IsAuthorized(api.DeleteLockout, true))
+
 	// COdecs
 
 	router.GET("/codecs", api.IsAuthorized(api.GetCodecs, true))
 	router.POST("/codecs", api.IsAuthorized(api.PostCodecs, true))
 	router.POST("/codecs/:codec_id", api.IsAuthorized(api.PostCodec, true))
 	router.DELETE("/codecs/:codec_id", api.IsAuthorized(api.DeleteCodec, true))
+	router.POST("/codecs/:codec_id/test", api.IsAuthorized(api.PostCodecTest, true))
+	router.GET("/codecs/:codec_id/stats", api.IsAuthorized(api.GetCodecStats, true))
+	router.GET("/codecs/:codec_id/versions", api.IsAuthorized(api.GetCodecVersions, true))
+	router.POST("/codecs/:codec_id/rollback", api.IsAuthorized(api.PostCodecRollback, true))
+	router.POST("/import/ttn", api.IsAuthorized(api.PostImportTTN, true))
+
+	// Device Templates
+
+	router.GET("/templates", api.IsAuthorized(api.GetTemplates, true))
+	router.POST("/templates", api.IsAuthorized(api.PostTemplates, true))
+	router.GET("/templates/:template_id", api.IsAuthorized(api.GetTemplate, true))
+	router.POST("/templates/:template_id", api.IsAuthorized(api.PostTemplate, true))
+	router.DELETE("/templates/:template_id", api.IsAuthorized(api.DeleteTemplate, true))
+	router.POST("/templates/:template_id/devices", api.IsAuthorized(api.PostTemplateDevices, true))
 
 	//Apps
 
@@ -63,6 +106,16 @@ func init() {
 	router.POST("/devices/:device_id/name", api.IsAuthorized(api.PostDeviceName, true))
 	router.GET("/devices/:device_id/meta", api.IsAuthorized(api.GetDeviceMeta, true))
 	router.POST("/devices/:device_id/meta", api.IsAuthorized(api.PostDeviceMeta, true))
+	router.GET("/devices/:device_id/tags", api.IsAuthorized(api.GetDeviceTags, true))
+	router.POST("/devices/:device_id/tags", api.IsAuthorized(api.PostDeviceTags, true))
+
+	// Tags (Device Groups)
+
+	router.GET("/tags", api.IsAuthorized(api.GetTags, true))
+	router.GET("/tags/:tag/values", api.IsAuthorized(api.GetTagValues, true))
+	router.POST("/tags/:tag/meta", api.IsAuthorized(api.PostTagMeta, true))
+	router.POST("/tags/:tag/codec", api.IsAuthorized(api.PostTagCodec, true))
+	router.DELETE("/tags/:tag/devices", api.IsAuthorized(api.DeleteTagDevices, true))
 
 	// Sensor Endpoints
 
@@ -97,6 +150,12 @@ func init() {
 	router.POST("/devices/:device_id/actuators/:actuator_id/value", api.IsAuthorized(api.PostDeviceActuatorValue, true))
 	router.POST("/devices/:device_id/actuators/:actuator_id/values", api.IsAuthorized(api.PostDeviceActuatorValues, true))
 
+	router.GET("/devices/:device_id/actuators/:actuator_id/commands", api.IsAuthorized(api.GetDeviceActuatorCommands, true))
+	router.POST("/devices/:device_id/actuators/:actuator_id/commands", api.IsAuthorized(api.PostDeviceActuatorCommands, true))
+	router.GET("/devices/:device_id/actuators/:actuator_id/commands/:command_id", api.IsAuthorized(api.GetDeviceActuatorCommand, true))
+	router.POST("/devices/:device_id/actuators/:actuator_id/commands/:command_id", api.IsAuthorized(api.PostDeviceActuatorCommand, true))
+	router.POST("/devices/:device_id/commands", api.IsAuthorized(api.PostDeviceCommands, true))
+
 	// Shortcut Endpoints (equals device_id = current device ID, true))
 
 	router.GET("/device", api.IsAuthorized(api.GetCurrentDevice, true))
@@ -124,6 +183,10 @@ func init() {
 	router.GET("/actuators/:actuator_id/values", api.IsAuthorized(api.GetActuatorValues, true))
 	router.POST("/actuators/:actuator_id/name", api.IsAuthorized(api.PostActuatorName, true))
 	router.POST("/actuators/:actuator_id/meta", api.IsAuthorized(api.PostActuatorMeta, true))
+	router.GET("/actuators/:actuator_id/commands", api.IsAuthorized(api.GetActuatorCommands, true))
+	router.POST("/actuators/:actuator_id/commands", api.IsAuthorized(api.PostActuatorCommands, true))
+	router.GET("/actuators/:actuator_id/commands/:command_id", api.IsAuthorized(api.GetActuatorCommand, true))
+	router.POST("/actuators/:actuator_id/commands/:command_id", api.IsAuthorized(api.PostActuatorCommand, true))
 
 	router.POST("/sensors/:sensor_id/value", api.IsAuthorized(api.PostSensorValue, true))
 	router.POST("/sensors/:sensor_id/values", api.IsAuthorized(api.PostSensorValues, true))
@@ -136,6 +199,10 @@ func init() {
 	router.POST("/messages", api.IsAuthorized(api.PostMessage, true /* true: check for IP based white list*/))
 	router.GET("/messages", api.IsAuthorized(api.GetMessages, true /* true: check for IP based white list*/))
 
+	// Audit
+
+	router.GET("/audit", api.IsAuthorized(api.GetAudit, true))
+
 	// Clouds configuration
 
 	router.GET("/clouds", api.IsAuthorized(api.GetClouds, true /* true: check for IP based white list*/))
@@ -160,6 +227,10 @@ func init() {
 	// Sys
 
 	router.GET("/sys/uptime", api.IsAuthorized(api.SysGetUptime, true /* true: check for IP based white list*/))
+	router.GET("/sys/trust", api.IsAuthorized(api.GetTrustPolicy, true))
+	router.POST("/sys/trust", api.IsAuthorized(api.PostTrustPolicy, true))
+	router.GET("/sys/oidc", api.IsAuthorized(api.GetOIDCConfig, true))
+	router.POST("/sys/oidc", api.IsAuthorized(api.PostOIDCConfig, true))
 	router.PUT("/sys/clear_all", api.IsAuthorized(api.SysClearAll, true /* true: check for IP based white list*/))
 	router.GET("/sys/logs", api.IsAuthorized(api.SysGetLogs, true /* true: check for IP based white list*/))
 	router.GET("/sys/log/:log_id", api.IsAuthorized(api.SysGetLog, true /* true: check for IP based white list*/))
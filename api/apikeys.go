@@ -0,0 +1,116 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// getRequestUser returns the logged in user.
+func getRequestUser(resp http.ResponseWriter, req *http.Request) (*edge.User, bool) {
+
+	userID, err := getAuthorizedUserID(req)
+	if err != nil {
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return nil, false
+	}
+	user, err := edge.GetUser(userID)
+	if err != nil {
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return nil, false
+	}
+	return &user, true
+}
+
+// GetAPIKeys implements GET /auth/keys
+//
+// Lists the API keys of the user. Admins get the keys of all users with ?all=true.
+func GetAPIKeys(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	userID := user.ID
+	if req.URL.Query().Get("all") == "true" && user.EffectiveRole() == edge.RoleAdmin {
+		userID = ""
+	}
+
+	keys, err := edge.GetAPIKeys(userID)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	tools.SendJSON(resp, keys)
+}
+
+// PostAPIKeys implements POST /auth/keys
+//
+// Creates a new API key. The key is only returned in this response:
+//
+//	{
+//	  "name": "weather station",
+//	  "permissions": ["devices:write"], // optional, all permissions of the user if empty
+//	  "devices": ["5cde6d034b9f610ff8373bdb"] // optional, all devices if empty
+//	}
+func PostAPIKeys(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	var apiKey edge.APIKey
+	if err := unmarshalRequestBody(req, &apiKey); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	apiKey.UserID = user.ID
+
+	key, err := edge.PostAPIKey(&apiKey)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] API key %s %q created for %q.", apiKey.Prefix, apiKey.Name, user.Username)
+
+	// the request body is published with MQTT, so do not add the key
+	tools.SetRequestBody(req, &apiKey)
+	tools.SendJSON(resp, struct {
+		edge.APIKey
+		Key string `json:"key"`
+	}{apiKey, key})
+}
+
+// DeleteAPIKey implements DELETE /auth/keys/{id}
+//
+// Revokes the API key. Admins can revoke the keys of all users.
+func DeleteAPIKey(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	apiKey, err := edge.GetAPIKey(params.ByName("key_id"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	if apiKey.UserID != user.ID && user.EffectiveRole() != edge.RoleAdmin {
+		http.Error(resp, "api key not found", http.StatusNotFound)
+		return
+	}
+
+	if err := edge.DeleteAPIKey(apiKey.ID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] API key %s %q revoked by %q.", apiKey.Prefix, apiKey.Name, user.Username)
+}
@@ -15,11 +15,13 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/Waziup/wazigate-edge/edge"
 	"github.com/Waziup/wazigate-edge/tools"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	routing "github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v3"
 )
 
 /*-----------------------------*/
@@ -419,11 +421,18 @@ func PostApp(resp http.ResponseWriter, req *http.Request, params routing.Params)
 		cmd := "docker-compose " + appConfig.Action
 		if appConfig.Action == "first-start" {
 			cmd = "docker-compose pull && docker-compose up -d --no-build"
+			// the pulled images may declare other permissions
+			var grantLog string
+			if err := grantAppPermissions(appID, appFullPath, &grantLog); err != nil {
+				log.Printf("[ERR  ] Granting the permissions of app %q: %v", appID, err)
+			}
 		}
 		out, err := tools.Shell(appFullPath, cmd)
 		if err != nil {
 			log.Printf("[Err  ] PostApp: %s", err.Error())
 			out = err.Error()
+		} else if appConfig.Action == "first-start" {
+			setAppContainers(appID, appFullPath)
 		}
 		if out == "" {
 			out = "[ " + appConfig.Action + " ] done"
@@ -1163,6 +1172,16 @@ func installApp(imageName string) (string, error) {
 
 	/*-----------*/
 
+	// The app key must be there before the app starts
+	if err := grantAppPermissions(appID, appFullPath, &installingAppStatus[appStatusIndex].log); err != nil {
+		installingAppStatus[appStatusIndex].done = true
+
+		msg = "Failed to grant the app permissions!"
+		return msg, err
+	}
+
+	/*-----------*/
+
 	// Pulling the dependencies
 	cmd = "cd \"" + appFullPath + "\" && docker-compose pull && docker-compose up -d --no-build"
 	outp, err = tools.ExecCommand(cmd, true)
@@ -1177,6 +1196,8 @@ func installApp(imageName string) (string, error) {
 		return msg, err
 	}
 
+	setAppContainers(appID, appFullPath)
+
 	/*-----------*/
 
 	/*outJson, err := json.Marshal( out)
@@ -1194,8 +1215,124 @@ func installApp(imageName string) (string, error) {
 
 /*-----------------------------*/
 
+// appKeyFile is the file in the app folder with the app key.
+// Apps that mount their folder to /var/lib/waziapp read it from /var/lib/waziapp/api-key.
+const appKeyFile = "api-key"
+
+// grantAppPermissions creates the app key with the permissions and topics declared in the
+// package.json of the app ("waziapp": {"permissions": [...], "topics": [...]}).
+// Apps that declare no permissions get no key and are trusted by the trust policy (see /sys/trust).
+func grantAppPermissions(appID string, appFullPath string, installLog *string) error {
+
+	appPkgRaw, err := ioutil.ReadFile(filepath.Join(appFullPath, "package.json"))
+	if err != nil {
+		return err
+	}
+	var appPkg struct {
+		Waziapp struct {
+			Permissions *[]string `json:"permissions"`
+			Topics      []string  `json:"topics"`
+		} `json:"waziapp"`
+	}
+	if err := json.Unmarshal(appPkgRaw, &appPkg); err != nil {
+		return err
+	}
+
+	if appPkg.Waziapp.Permissions == nil && appPkg.Waziapp.Topics == nil {
+		*installLog += "\nThe app declares no permissions, it is trusted with the docker role of the trust policy.\n"
+		return edge.DeleteAppKeys(appID)
+	}
+
+	var permissions []string
+	if appPkg.Waziapp.Permissions != nil {
+		permissions = *appPkg.Waziapp.Permissions
+	}
+	*installLog += "\nGranting the requested permissions:\n"
+	for _, p := range permissions {
+		*installLog += "  - " + p + "\n"
+	}
+	for _, t := range appPkg.Waziapp.Topics {
+		*installLog += "  - topic " + t + "\n"
+	}
+
+	key, err := edge.PostAppKey(appID, permissions, appPkg.Waziapp.Topics, composeContainerNames(appFullPath))
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(appFullPath, appKeyFile), []byte(key), 0600); err != nil {
+		edge.DeleteAppKeys(appID)
+		return err
+	}
+	log.Printf("[INFO ] App %q granted permissions %v, topics %v.", appID, permissions, appPkg.Waziapp.Topics)
+	return nil
+}
+
+// composeContainerNames returns the 'container_name' of the services in the docker-compose.yml of the app.
+func composeContainerNames(appFullPath string) []string {
+
+	data, err := ioutil.ReadFile(filepath.Join(appFullPath, "docker-compose.yml"))
+	if err != nil {
+		return nil
+	}
+	var compose struct {
+		Services map[string]struct {
+			ContainerName string `yaml:"container_name"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		log.Printf("[WARN ] docker-compose.yml of %q: %v", appFullPath, err)
+		return nil
+	}
+	var names []string
+	for _, service := range compose.Services {
+		if service.ContainerName != "" {
+			names = append(names, service.ContainerName)
+		}
+	}
+	return names
+}
+
+// setAppContainers stores the names of the running containers of the app with its app key,
+// so that requests from these containers are not trusted by IP address.
+func setAppContainers(appID string, appFullPath string) {
+
+	ids, err := tools.Shell(appFullPath, "docker-compose ps -q")
+	if err != nil {
+		log.Printf("[ERR  ] Containers of app %q: %v", appID, err)
+		return
+	}
+	names := composeContainerNames(appFullPath)
+	for _, id := range strings.Fields(ids) {
+		dockerJSONRaw, err := tools.SockGetRequest("containers/" + id + "/json")
+		if err != nil {
+			log.Printf("[ERR  ] Can not inspect container %s of app %q: %v", id, appID, err)
+			continue
+		}
+		var container struct {
+			Name string `json:"Name"`
+		}
+		if err := json.Unmarshal(dockerJSONRaw, &container); err != nil {
+			log.Printf("[ERR  ] Can not unmarshal \"docker inspect\" response: %v", err)
+			continue
+		}
+		name := strings.TrimPrefix(container.Name, "/")
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	if err := edge.SetAppContainers(appID, names); err != nil {
+		log.Printf("[ERR  ] Containers of app %q: %v", appID, err)
+	}
+}
+
+/*-----------------------------*/
+
 func uninstallApp(appID string, keepConfig bool) error {
 
+	if err := edge.DeleteAppKeys(appID); err != nil {
+		log.Printf("[ERR  ] Revoking the key of app %q: %v", appID, err)
+	}
+
 	var out string
 	var err error
 
@@ -1221,3 +1358,12 @@ func uninstallApp(appID string, keepConfig bool) error {
 }
 
 /*-----------------------------*/
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
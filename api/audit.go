@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	jwt "github.com/dgrijalva/jwt-go"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// auditMaxChange is the maximum length of the change summary of audit entries.
+const auditMaxChange = 512
+
+// isAuditSecret checks if the values of that JSON key are not written to the audit log.
+func isAuditSecret(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "token", "key", "apikey", "api_key":
+		return true
+	}
+	return strings.Contains(key, "password") || strings.Contains(key, "secret")
+}
+
+// Audit records a state-changing request in the audit log.
+// The body is the request body, or the replaced body (see tools.SetRequestBody) if any.
+func Audit(req *http.Request, status int, body []byte) {
+
+	entity := strings.TrimPrefix(req.URL.Path, "/")
+	entry := edge.AuditEntry{
+		Actor:  requestAuditActor(req),
+		Proto:  req.Header.Get("X-Proto"),
+		Method: req.Method,
+		Entity: entity,
+		Status: status,
+	}
+	if req.Method == http.MethodDelete {
+		entry.Change = "deleted"
+	} else if !strings.HasPrefix(entity, "auth/") {
+		entry.Change = auditChange(body)
+	}
+	if err := edge.PostAuditEntry(&entry); err != nil {
+		log.Printf("[ERR  ] Audit: %v", err)
+	}
+}
+
+// auditChange returns a short summary of the request body without secrets.
+func auditChange(body []byte) string {
+
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		body, _ = json.Marshal(hideSecrets(v))
+	} else if !utf8.Valid(body) {
+		return fmt.Sprintf("%d bytes", len(body))
+	}
+	if len(body) > auditMaxChange {
+		return string(body[:auditMaxChange]) + "..."
+	}
+	return string(body)
+}
+
+func hideSecrets(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if isAuditSecret(key) {
+				t[key] = "***"
+			} else {
+				t[key] = hideSecrets(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range t {
+			t[i] = hideSecrets(value)
+		}
+	}
+	return v
+}
+
+// requestAuditActor returns who sent the request.
+func requestAuditActor(req *http.Request) edge.AuditActor {
+
+	// the headers are set by the edge for MQTT clients, not by the request
+	if req.Header.Get("X-Proto") == "mqtt" {
+		login := requestMQTTLogin(req)
+		if login != nil && login.CloudID != "" {
+			return edge.AuditActor{Type: edge.ActorCloud, ID: login.CloudID}
+		}
+		if login != nil && login.APIKeyID != "" {
+			if apiKey, err := edge.GetAPIKey(login.APIKeyID); err == nil {
+				return apiKeyActor(apiKey)
+			}
+		}
+		actor := edge.AuditActor{Type: edge.ActorMQTT, ID: req.RemoteAddr}
+		if login != nil && login.UserID != "" {
+			actor.UserID = login.UserID
+			if user, err := edge.GetUser(login.UserID); err == nil {
+				actor.Username = user.Username
+			}
+		}
+		return actor
+	}
+
+	if reqToken := getRequestToken(req); reqToken != "" {
+		if strings.HasPrefix(reqToken, edge.APIKeyPrefix) {
+			if apiKey, err := edge.CheckAPIKey(reqToken); err == nil {
+				return apiKeyActor(apiKey)
+			}
+		} else if token, err := CheckToken(reqToken); err == nil {
+			userID, _ := token.Claims.(jwt.MapClaims)["client"].(string)
+			actor := edge.AuditActor{Type: edge.ActorUser, ID: userID, UserID: userID}
+			if user, err := edge.GetUser(userID); err == nil {
+				actor.Username = user.Username
+			}
+			return actor
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	actor := edge.AuditActor{Type: edge.ActorIP, ID: host}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			actor.Type = edge.ActorLocal
+		} else if ok, _ := IsDockerSubnet(ip); ok {
+			actor.Type = edge.ActorContainer
+			actor.Name = dockerAppName(ip)
+		}
+	}
+	return actor
+}
+
+func apiKeyActor(apiKey *edge.APIKey) edge.AuditActor {
+	actor := edge.AuditActor{
+		Type:   edge.ActorAPIKey,
+		ID:     apiKey.ID,
+		Name:   apiKey.Name,
+		UserID: apiKey.UserID,
+	}
+	if user, err := edge.GetUser(apiKey.UserID); err == nil {
+		actor.Username = user.Username
+	}
+	return actor
+}
+
+// GetAudit implements GET /audit
+//
+// Query parameters: from, to, limit, actor (type), actorId, user (user ID), entity (path prefix) and method.
+func GetAudit(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var query edge.AuditQuery
+	if err := query.Parse(req); err != "" {
+		http.Error(resp, "bad request: "+err, http.StatusBadRequest)
+		return
+	}
+
+	entries := edge.GetAudit(&query)
+	defer entries.Close()
+	encoder := json.NewEncoder(resp)
+
+	entry, err := entries.Next()
+	if err != nil && err.Error() != "EOF" {
+		serveError(resp, err)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write([]byte{'['})
+	for entry != nil {
+		encoder.Encode(entry)
+		entry, _ = entries.Next()
+		if entry != nil {
+			resp.Write([]byte{','})
+		}
+	}
+	resp.Write([]byte{']'})
+}
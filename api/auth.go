@@ -1,13 +1,13 @@
 package api
 
 import (
-	"crypto/rand"
-	"encoding/base32"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +34,9 @@ const (
 /*---------------------*/
 
 // GetToken implements POST /auth/token
+//
+// Users with TOTP enabled must send the TOTP code (or a recovery code) as "totp".
+// Without code, the response is 401 with the header "X-TOTP-Required: true".
 func GetToken(resp http.ResponseWriter, req *http.Request, params routing.Params) {
 
 	clientsRequestAccept := req.Header.Get("accept")
@@ -45,7 +48,10 @@ func GetToken(resp http.ResponseWriter, req *http.Request, params routing.Params
 		return
 	}
 
-	var inputUser edge.User
+	var inputUser struct {
+		edge.User
+		TOTP string `json:"totp"`
+	}
 
 	err = json.Unmarshal(body, &inputUser)
 	if err != nil {
@@ -56,17 +62,61 @@ func GetToken(resp http.ResponseWriter, req *http.Request, params routing.Params
 
 	// log.Printf("Input User: %q", inputUser)
 
+	addr, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		addr = req.RemoteAddr
+	}
+
+	if wait := edge.CheckLogin(inputUser.Username, addr); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		log.Printf("[WARN ] GetToken: login of %q from %s throttled for %ds", inputUser.Username, addr, seconds)
+		resp.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(resp, fmt.Sprintf("Too many failed logins, retry in %ds", seconds), http.StatusTooManyRequests)
+		return
+	}
+
 	validUser, err := edge.CheckUserCredentials(inputUser.Username, inputUser.Password)
 
 	if err != nil {
 		log.Printf("[ERR  ] GetToken: %s", err.Error())
+		edge.LoginFailed(inputUser.Username, addr)
 		http.Error(resp, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
+	if validUser.TOTPEnabled {
+		if inputUser.TOTP == "" {
+			resp.Header().Set("X-TOTP-Required", "true")
+			http.Error(resp, "TOTP code required", http.StatusUnauthorized)
+			return
+		}
+		if err := edge.CheckTOTP(&validUser, inputUser.TOTP); err != nil {
+			log.Printf("[ERR  ] GetToken: %s", err.Error())
+			edge.LoginFailed(inputUser.Username, addr)
+			http.Error(resp, "Invalid TOTP code", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	edge.LoginSucceeded(inputUser.Username, addr)
+
+	// the request body is published with MQTT, so remove the password and code
+	tools.SetRequestBody(req, map[string]string{"username": validUser.Username})
+
 	//Login success.
 
-	tokenString, err := generateToken(validUser.ID)
+	session := edge.Session{
+		UserID:    validUser.ID,
+		Addr:      req.RemoteAddr,
+		UserAgent: req.UserAgent(),
+	}
+	if err := edge.PostSession(&session); err != nil {
+		log.Printf("[ERR  ] GetToken: %s", err.Error())
+		http.Error(resp, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	tokenString, err := generateToken(validUser.ID, session.ID)
 
 	if err != nil {
 		// resp.WriteHeader(http.StatusForbidden)
@@ -112,7 +162,7 @@ func GetRefereshToken(resp http.ResponseWriter, req *http.Request, params routin
 
 	clientsRequestAccept := req.Header.Get("accept")
 
-	userID, err := getAuthorizedUserID(req)
+	token, err := CheckToken(getRequestToken(req))
 
 	if err != nil {
 		log.Printf("[ERR  ] GetRefereshToken: %s", err.Error())
@@ -120,7 +170,17 @@ func GetRefereshToken(resp http.ResponseWriter, req *http.Request, params routin
 		return
 	}
 
-	tokenString, err := generateToken(userID)
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := claims["client"].(string)
+	sessionID, _ := claims["sid"].(string)
+
+	if err := edge.RefreshSession(sessionID); err != nil {
+		log.Printf("[ERR  ] GetRefereshToken: %s", err.Error())
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	tokenString, err := generateToken(userID, sessionID)
 
 	if err != nil {
 		log.Printf("[ERR  ] GetRefereshToken: %s", err.Error())
@@ -161,7 +221,8 @@ func GetRefereshToken(resp http.ResponseWriter, req *http.Request, params routin
 
 /*---------------------*/
 
-func getAuthorizedUserID(req *http.Request) (string, error) {
+// getRequestToken returns the token (or API key) from the Authorization header or the Token cookie.
+func getRequestToken(req *http.Request) string {
 
 	reqToken := ""
 
@@ -183,13 +244,25 @@ func getAuthorizedUserID(req *http.Request) (string, error) {
 		}
 	}
 
-	/*---------*/
+	return reqToken
+}
+
+func getAuthorizedUserID(req *http.Request) (string, error) {
 
+	reqToken := getRequestToken(req)
 	if len(reqToken) == 0 {
 
 		return "", fmt.Errorf("Not Authorized")
 	}
 
+	if strings.HasPrefix(reqToken, edge.APIKeyPrefix) {
+		apiKey, err := edge.CheckAPIKey(reqToken)
+		if err != nil {
+			return "", err
+		}
+		return apiKey.UserID, nil
+	}
+
 	token, err := CheckToken(reqToken)
 	if err != nil {
 		return "", err
@@ -202,12 +275,33 @@ func getAuthorizedUserID(req *http.Request) (string, error) {
 	return claims["client"].(string), nil
 }
 
+// getRequestActor returns who sent the request:
+// the username, "mqtt" for MQTT clients or the remote IP address.
+func getRequestActor(req *http.Request) string {
+
+	actor := requestAuditActor(req)
+	if actor.Username != "" {
+		return actor.Username
+	}
+	if req.Header.Get("X-Proto") == "mqtt" {
+		return "mqtt"
+	}
+	return actor.ID
+}
+
+// CheckToken validates the token with the signing key of its 'kid' header
+// and checks that the session of the token has not been revoked.
 func CheckToken(t string) (*jwt.Token, error) {
 	token, err := jwt.Parse(t, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("There was an error")
 		}
-		return getSecret(), nil
+		keyID, _ := token.Header["kid"].(string)
+		key := edge.GetSigningKey(keyID)
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", keyID)
+		}
+		return key.Secret, nil
 	})
 	if err != nil {
 		return nil, err
@@ -215,22 +309,33 @@ func CheckToken(t string) (*jwt.Token, error) {
 	if !token.Valid {
 		return nil, fmt.Errorf("Invalid Token")
 	}
+	sessionID, _ := token.Claims.(jwt.MapClaims)["sid"].(string)
+	if _, err := edge.CheckSession(sessionID); err != nil {
+		return nil, err
+	}
 	return token, nil
 }
 
 /*---------------------*/
 
-func generateToken(userID string) (string, error) {
+func generateToken(userID string, sessionID string) (string, error) {
+
+	key, err := edge.CurrentSigningKey()
+	if err != nil {
+		return "", err
+	}
 
 	token := jwt.New(jwt.SigningMethodHS256)
+	token.Header["kid"] = key.ID
 
 	claims := token.Claims.(jwt.MapClaims)
 
 	claims["authorized"] = true
 	claims["client"] = userID
+	claims["sid"] = sessionID
 	claims["exp"] = time.Now().Add(time.Minute * tokenExpTimeMinutes).Unix()
 
-	tokenString, err := token.SignedString(getSecret())
+	tokenString, err := token.SignedString(key.Secret)
 
 	if err != nil {
 		return "", err
@@ -241,29 +346,9 @@ func generateToken(userID string) (string, error) {
 
 /*---------------------*/
 
-var tokenSecret []byte
-
-func getSecret() []byte {
-
-	if tokenSecret != nil {
-		return tokenSecret
-	}
-
-	secretMinSize := 40
-	someBytes := make([]byte, 32)
-
-	_, err := rand.Read(someBytes)
-	if err != nil {
-		log.Printf("[ERR  ] GetSecret: %s", err.Error())
-	}
-
-	tokenSecret = []byte(base32.StdEncoding.EncodeToString(someBytes)[:secretMinSize])
-	return tokenSecret
-}
-
-/*---------------------*/
-
 // PostUserProfile implements POST /auth/profile
+//
+// Changing the password ("newPassword") logs out all other sessions of the user.
 func PostUserProfile(resp http.ResponseWriter, req *http.Request, params routing.Params) {
 
 	body, err := tools.ReadAll(req.Body)
@@ -294,10 +379,22 @@ func PostUserProfile(resp http.ResponseWriter, req *http.Request, params routing
 
 	if err != nil {
 		log.Printf("[ERR  ] PostUserProfile: %s", err.Error())
-		http.Error(resp, err.Error(), http.StatusUnauthorized)
+		serveError(resp, err)
 		return
 	}
 
+	if inputProfile.NewPassword != "" {
+		n, err := edge.DeleteOtherSessions(userID, getRequestSessionID(req))
+		if err != nil {
+			log.Printf("[ERR  ] PostUserProfile: %s", err.Error())
+		} else if n != 0 {
+			log.Printf("[INFO ] Password changed: %d other sessions of user %s logged out.", n, userID)
+		}
+	}
+
+	// the request body is published with MQTT, so remove the passwords
+	tools.SetRequestBody(req, map[string]string{"name": inputProfile.Name})
+
 	tools.SendJSON(resp, "Profile changes saved successfully.")
 }
 
@@ -322,6 +419,7 @@ func GetUserProfile(resp http.ResponseWriter, req *http.Request, params routing.
 	}
 
 	user.Password = ""
+	user.Role = user.EffectiveRole()
 
 	tools.SendJSON(resp, user)
 
@@ -329,17 +427,292 @@ func GetUserProfile(resp http.ResponseWriter, req *http.Request, params routing.
 
 /*---------------------*/
 
+// Permissions are the effective permissions of the caller.
+type Permissions struct {
+	Username    string   `json:"username"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+	// Devices are set if the caller uses an API key limited to these devices.
+	Devices []string `json:"devices,omitempty"`
+	// App is the app of an app key, or the container name for trusted apps without token.
+	App string `json:"app,omitempty"`
+	// Topics are the topics declared by the app, for app keys.
+	Topics []string `json:"topics,omitempty"`
+}
+
 // GetPermissions implements GET /auth/permissions
 func GetPermissions(resp http.ResponseWriter, req *http.Request, params routing.Params) {
 
-	// TODO: implement
-	tools.SendJSON(resp, "GetPermissions()")
+	if apiKey := getRequestAPIKey(req); apiKey != nil && apiKey.App != "" {
+		tools.SendJSON(resp, Permissions{
+			Permissions: apiKey.Permissions,
+			Topics:      apiKey.Topics,
+			App:         apiKey.App,
+		})
+		return
+	}
+
+	userID, err := getAuthorizedUserID(req)
+	if err != nil {
+		// trusted clients (see the trust policy) have no token
+		var role, app string
+		if ip := requestIP(req); ip != nil {
+			role, app = trustedRole(ip)
+		}
+		tools.SendJSON(resp, Permissions{
+			Role:        role,
+			Permissions: edge.Permissions(role),
+			App:         app,
+		})
+		return
+	}
+
+	user, err := edge.GetUser(userID)
+	if err != nil {
+		log.Printf("[ERR  ] GetPermissions: %s", err.Error())
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	perms := Permissions{
+		Username:    user.Username,
+		Role:        user.EffectiveRole(),
+		Permissions: edge.Permissions(user.EffectiveRole()),
+	}
+	if apiKey := getRequestAPIKey(req); apiKey != nil {
+		if len(apiKey.Permissions) != 0 {
+			perms.Permissions = apiKey.Permissions
+		}
+		perms.Devices = apiKey.Devices
+	}
+	tools.SendJSON(resp, perms)
+}
+
+// getRequestAPIKey returns the API key of the request, or nil if there is none.
+func getRequestAPIKey(req *http.Request) *edge.APIKey {
+	reqToken := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !strings.HasPrefix(reqToken, edge.APIKeyPrefix) {
+		return nil
+	}
+	apiKey, err := edge.CheckAPIKey(reqToken)
+	if err != nil {
+		return nil
+	}
+	return apiKey
+}
+
+/*---------------------*/
+
+// permissionResources maps the first path segment to the resource of the permission.
+var permissionResources = map[string]string{
+	"auth":       "",
+	"devices":    "devices",
+	"device":     "devices",
+	"sensors":    "devices",
+	"actuators":  "devices",
+	"tags":       "devices",
+	"templates":  "devices",
+	"messages":   "devices",
+	"codecs":     "codecs",
+	"import":     "codecs",
+	"apps":       "apps",
+	"update":     "apps",
+	"clouds":     "clouds",
+	"users":      "users",
+	"lockouts":   "users",
+	"sys":        "sys",
+	"audit":      "users",
+	"exportall":  "sys",
+	"exporttree": "sys",
+	"exportbins": "sys",
+	"version":    "sys",
+	"buildnr":    "sys",
+	"info":       "sys",
+}
+
+// requiredPermission returns the permission needed for the request, like "devices:write".
+// It returns "" if any logged in user may do this request.
+func requiredPermission(req *http.Request) string {
+
+	if req.Header.Get("Upgrade") == "websocket" {
+		// MQTT over WebSocket to subscribe to values
+		return "devices:read"
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		path = path[:i]
+	}
+	resource, ok := permissionResources[path]
+	if !ok {
+		resource = "sys"
+	}
+	if resource == "" {
+		return ""
+	}
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+// checkPermission checks if the user may do that request.
+func checkPermission(resp http.ResponseWriter, req *http.Request, userID string) bool {
+
+	user, err := edge.GetUser(userID)
+	if err != nil {
+		log.Printf("[ERR  ] Auth: user %q: %s", userID, err.Error())
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+
+	if !checkPasswordChanged(resp, req, &user) {
+		return false
+	}
+
+	permission := requiredPermission(req)
+	if permission != "" && !edge.HasPermission(user.EffectiveRole(), permission) {
+		log.Printf("[WARN ] Auth: user %q (%s) has no permission %q for %s %s", user.Username, user.EffectiveRole(), permission, req.Method, req.URL.Path)
+		http.Error(resp, "forbidden: missing permission "+permission, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkAPIKeyPermission checks if the API key may do that request.
+// API keys can not be used for /auth calls except /auth/permissions,
+// so they can not create tokens or other keys.
+func checkAPIKeyPermission(resp http.ResponseWriter, req *http.Request, apiKey *edge.APIKey) bool {
+
+	if strings.HasPrefix(req.URL.Path, "/auth/") && req.URL.Path != "/auth/permissions" {
+		http.Error(resp, "forbidden: api keys can not be used for "+req.URL.Path, http.StatusForbidden)
+		return false
+	}
+
+	// app keys have no user, they are limited by the permissions declared by the app
+	var user edge.User
+	role := edge.RoleAdmin
+	if apiKey.App != "" {
+		user.Username = "app " + apiKey.App
+	} else {
+		var err error
+		user, err = edge.GetUser(apiKey.UserID)
+		if err != nil {
+			log.Printf("[ERR  ] Auth: api key %s: user %q: %s", apiKey.Prefix, apiKey.UserID, err.Error())
+			http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return false
+		}
+		if !checkPasswordChanged(resp, req, &user) {
+			return false
+		}
+		role = user.EffectiveRole()
+	}
+
+	// the topics of app keys allow to read and to publish with MQTT, but not to change or delete with HTTP
+	topic := ""
+	if req.Method == http.MethodGet || req.Method == http.MethodHead || req.Header.Get("X-Proto") == "mqtt" {
+		topic = strings.TrimPrefix(req.URL.Path, "/")
+	}
+	permission := requiredPermission(req)
+	if !apiKey.Allows(role, permission, requestDeviceID(req), topic) {
+		log.Printf("[WARN ] Auth: api key %s of %q does not allow %s %s", apiKey.Prefix, user.Username, req.Method, req.URL.Path)
+		http.Error(resp, "forbidden: the api key does not allow this request", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requestAllows checks if the (authorized) caller of the request has the permission,
+// for responses that depend on the permissions of the caller.
+func requestAllows(req *http.Request, permission string) bool {
+
+	if req.Header.Get("X-Proto") == "mqtt" {
+		return false
+	}
+
+	if ip := requestIP(req); ip != nil {
+		if role, _ := trustedRole(ip); role != "" && edge.HasPermission(role, permission) {
+			return true
+		}
+	}
+
+	reqToken := getRequestToken(req)
+	if reqToken == "" {
+		return false
+	}
+	if strings.HasPrefix(reqToken, edge.APIKeyPrefix) {
+		apiKey, err := edge.CheckAPIKey(reqToken)
+		if err != nil {
+			return false
+		}
+		role := edge.RoleAdmin
+		if apiKey.App == "" {
+			user, err := edge.GetUser(apiKey.UserID)
+			if err != nil {
+				return false
+			}
+			role = user.EffectiveRole()
+		}
+		return apiKey.Allows(role, permission, "", "")
+	}
+	userID, err := getAuthorizedUserID(req)
+	if err != nil {
+		return false
+	}
+	user, err := edge.GetUser(userID)
+	return err == nil && edge.HasPermission(user.EffectiveRole(), permission)
+}
+
+// StatusPasswordChangeRequired is the status of all requests of users that must change their password.
+const StatusPasswordChangeRequired = http.StatusPreconditionRequired
+
+// passwordChangeRoutes can be used by users that must change their password.
+var passwordChangeRoutes = map[string]bool{
+	"/auth/profile":     true,
+	"/auth/permissions": true,
+	"/auth/retoken":     true,
+	"/auth/sessions":    true,
+}
+
+// checkPasswordChanged rejects requests of users that must change their password,
+// except for the routes to change the password.
+func checkPasswordChanged(resp http.ResponseWriter, req *http.Request, user *edge.User) bool {
+
+	if !user.MustChangePassword || passwordChangeRoutes[req.URL.Path] {
+		return true
+	}
+	http.Error(resp, "password change required: change the password at POST /auth/profile", StatusPasswordChangeRequired)
+	return false
+}
+
+// requestDeviceID returns the device of /devices/{id}/... requests, or "".
+func requestDeviceID(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, "/devices/")
+	if path == req.URL.Path {
+		return ""
+	}
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		path = path[:i]
+	}
+	return path
 }
 
 /*---------------------*/
 
 // Logout implements GET /auth/logout
+//
+// The session of the token is revoked, so the token can not be used anymore.
 func Logout(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	if token, err := CheckToken(getRequestToken(req)); err == nil {
+		claims := token.Claims.(jwt.MapClaims)
+		userID, _ := claims["client"].(string)
+		sessionID, _ := claims["sid"].(string)
+		if err := edge.DeleteSession(userID, sessionID); err != nil {
+			log.Printf("[ERR  ] Logout: %s", err.Error())
+		}
+	}
+
 	c := http.Cookie{
 		Name:     "Token",
 		Path:     "/",
@@ -349,7 +722,6 @@ func Logout(resp http.ResponseWriter, req *http.Request, params routing.Params)
 		MaxAge:   -1}
 	http.SetCookie(resp, &c)
 
-	//TODO: Other actions that we may need to do
 	tools.SendJSON(resp, "Logged out.")
 }
 
@@ -360,10 +732,12 @@ func IsAuthorized(endpoint routing.Handle, checkIPWhiteList bool) routing.Handle
 
 	return func(resp http.ResponseWriter, req *http.Request, params routing.Params) {
 
-		// mqtt connections are already logged in & authorized
+		// mqtt clients are logged in with CONNECT, but every publish is checked
 		// the header is set by the edge and not by the request!
 		if req.Header.Get("X-Proto") == "mqtt" {
-			endpoint(resp, req, params)
+			if checkMQTTPermission(resp, req) {
+				endpoint(resp, req, params)
+			}
 			return
 		}
 
@@ -379,16 +753,19 @@ func IsAuthorized(endpoint routing.Handle, checkIPWhiteList bool) routing.Handle
 				log.Printf("[ERR  ] Whitelist check failed: Invalid req.RemoteAddr: %q", req.RemoteAddr)
 				return
 			}
-			ok, err := IsDockerSubnet(reqIP)
-			if err != nil {
-				log.Printf("[ERR  ] Whitelist check for docker subnet failed for %q: %v", req.RemoteAddr, err)
-			}
-			if !ok && reqIP.IsLoopback() {
-				ok = true
-			}
-			if ok {
-				endpoint(resp, req, params)
-				return
+			// trusted networks and apps need no token, but are limited to the role of the trust policy
+			if role, app := trustedRole(reqIP); role != "" {
+				permission := requiredPermission(req)
+				if permission == "" || edge.HasPermission(role, permission) {
+					endpoint(resp, req, params)
+					return
+				}
+				// requests with a token may have more permissions than the trusted role
+				if getRequestToken(req) == "" {
+					log.Printf("[WARN ] Auth: trusted %s %s (%s) has no permission %q for %s %s", reqIP, app, role, permission, req.Method, req.URL.Path)
+					http.Error(resp, "forbidden: missing permission "+permission, http.StatusForbidden)
+					return
+				}
 			}
 		}
 
@@ -415,14 +792,21 @@ func IsAuthorized(endpoint routing.Handle, checkIPWhiteList bool) routing.Handle
 			}
 		}
 
-		if reqToken != "" {
+		if strings.HasPrefix(reqToken, edge.APIKeyPrefix) {
 
-			token, err := jwt.Parse(reqToken, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("There was an error")
-				}
-				return getSecret(), nil
-			})
+			apiKey, err := edge.CheckAPIKey(reqToken)
+			if err != nil {
+				log.Printf("[ERR  ] Auth error: %s", err.Error())
+				http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if checkAPIKeyPermission(resp, req, apiKey) {
+				endpoint(resp, req, params)
+			}
+
+		} else if reqToken != "" {
+
+			token, err := CheckToken(reqToken)
 
 			if err != nil {
 				log.Printf("[ERR  ] Auth error: %s", err.Error())
@@ -431,7 +815,10 @@ func IsAuthorized(endpoint routing.Handle, checkIPWhiteList bool) routing.Handle
 			}
 
 			if token.Valid {
-				endpoint(resp, req, params)
+				userID, _ := token.Claims.(jwt.MapClaims)["client"].(string)
+				if checkPermission(resp, req, userID) {
+					endpoint(resp, req, params)
+				}
 			}
 
 		} else {
@@ -444,8 +831,6 @@ func IsAuthorized(endpoint routing.Handle, checkIPWhiteList bool) routing.Handle
 
 /*---------------------*/
 
-var listOfWhiteIPs map[string]interface{} = map[string]interface{}{}
-
 var wazigateSubnet *net.IPNet
 
 // IsDockerSubnet calls `docker network inspect wazigate` to get the wazigate subnet from docker.
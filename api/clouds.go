@@ -16,9 +16,16 @@ import (
 )
 
 // GetClouds implements GET /clouds
+//
+// The cloud token (password) is only shown to callers with the permission clouds:write.
 func GetClouds(resp http.ResponseWriter, req *http.Request, params routing.Params) {
 
-	data, err := json.Marshal(clouds.GetClouds())
+	showToken := requestAllows(req, "clouds:write")
+	list := make(map[string]json.RawMessage)
+	for id, cloud := range clouds.GetClouds() {
+		list[id] = marshalCloud(cloud, showToken)
+	}
+	data, err := json.Marshal(list)
 	if err != nil {
 		log.Printf("[ERR  ] Error %v", err)
 		http.Error(resp, "internal server error", http.StatusInternalServerError)
@@ -89,8 +96,22 @@ func GetCloud(resp http.ResponseWriter, req *http.Request, params routing.Params
 	}
 
 	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(marshalCloud(cloud, requestAllows(req, "clouds:write")))
+}
+
+// marshalCloud returns the cloud as JSON, without the token if showToken is false.
+func marshalCloud(cloud *clouds.Cloud, showToken bool) json.RawMessage {
 	data, _ := json.Marshal(cloud)
-	resp.Write(data)
+	if showToken {
+		return data
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return data
+	}
+	delete(fields, "token")
+	data, _ = json.Marshal(fields)
+	return data
 }
 
 // PostCloudRESTAddr implements POST /clouds/{cloudID}/rest
@@ -1,9 +1,14 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/Waziup/wazigate-edge/edge"
 	"github.com/Waziup/wazigate-edge/tools"
@@ -44,6 +49,7 @@ func PostCodec(resp http.ResponseWriter, req *http.Request, params routing.Param
 		return
 	}
 	codec.ID = codecID
+	codec.Author = getRequestActor(req)
 
 	if err := edge.PostCodec(&codec); err != nil {
 		serveError(resp, err)
@@ -77,6 +83,7 @@ func PostCodecs(resp http.ResponseWriter, req *http.Request, params routing.Para
 		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	codec.Author = getRequestActor(req)
 
 	if err := edge.PostCodec(&codec); err != nil {
 		serveError(resp, err)
@@ -89,3 +96,113 @@ func PostCodecs(resp http.ResponseWriter, req *http.Request, params routing.Para
 
 	resp.Write([]byte(codec.ID))
 }
+
+// PostCodecTest implements POST /codecs/{id}/test
+//
+// The codec decodes the payload for a scratch device without persisting anything:
+//
+//	{
+//	  "payload": "0167011a",
+//	  "encoding": "hex", // or "base64", "raw"
+//	  "fPort": 1,
+//	  "headers": {"X-LoRaWAN-RSSI": "-80"},
+//	  "device": {"sensors": [...]} // optional
+//	}
+func PostCodecTest(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var test struct {
+		Payload  string            `json:"payload"`
+		Encoding string            `json:"encoding"`
+		FPort    int               `json:"fPort"`
+		Headers  map[string]string `json:"headers"`
+		Device   *edge.Device      `json:"device"`
+	}
+	if err := unmarshalRequestBody(req, &test); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload []byte
+	var err error
+	switch test.Encoding {
+	case "", "hex":
+		payload, err = hex.DecodeString(strings.ReplaceAll(test.Payload, " ", ""))
+	case "base64":
+		payload, err = base64.StdEncoding.DecodeString(test.Payload)
+	case "raw":
+		payload = []byte(test.Payload)
+	default:
+		err = fmt.Errorf("unknown encoding %q, use 'hex', 'base64' or 'raw'", test.Encoding)
+	}
+	if err != nil {
+		http.Error(resp, "bad request: payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	script, err := edge.GetCodec(params.ByName("codec_id"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	var codec edge.Codec = script
+	if script.Internal {
+		codec = edge.Codecs[script.ID]
+	}
+
+	headers := http.Header{}
+	for key, value := range test.Headers {
+		headers.Set(key, value)
+	}
+	if test.FPort != 0 {
+		headers.Set("X-LoRaWAN-FPort", strconv.Itoa(test.FPort))
+	}
+
+	tools.SendJSON(resp, edge.TestCodec(codec, test.Device, headers, payload))
+}
+
+// GetCodecStats implements GET /codecs/{id}/stats
+func GetCodecStats(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	codecID := params.ByName("codec_id")
+	if _, err := edge.GetCodec(codecID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	tools.SendJSON(resp, edge.GetCodecStats(codecID))
+}
+
+// GetCodecVersions implements GET /codecs/{id}/versions
+func GetCodecVersions(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	versions, err := edge.GetCodecVersions(params.ByName("codec_id"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	tools.SendJSON(resp, versions)
+}
+
+// PostCodecRollback implements POST /codecs/{id}/rollback
+//
+// The body is the version number that becomes the current version again.
+func PostCodecRollback(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var version int
+	if err := unmarshalRequestBody(req, &version); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codec, err := edge.RollbackCodec(params.ByName("codec_id"), version, getRequestActor(req))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] Codec %s rolled back to version %d (now version %d).", codec.ID, version, codec.Version)
+
+	tools.SetRequestBody(req, codec)
+	tools.SendJSON(resp, codec.Version)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Waziup/wazigate-edge/edge/codecs/ttn"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// maxImportSize is the max. size of an uploaded repository zip.
+// The upload is buffered in a temporary file, not in memory.
+const maxImportSize = 256 << 20
+
+// TTNImportDir is the folder with local checkouts or zips of the repository.
+// Imports with a 'path' can only read from this folder.
+var TTNImportDir = "/var/lib/lorawan-devices"
+
+func init() {
+	if dir := os.Getenv("WAZIUP_TTN_IMPORT_DIR"); dir != "" {
+		TTNImportDir = dir
+	}
+}
+
+// PostImportTTN implements POST /import/ttn
+//
+// Imports the codecs of a device from the TTN Device Repository (lorawan-devices).
+// Either upload a zip of the repository with 'Content-Type: application/zip' and
+// the query '?vendor={vendor}&device={device}', or use a checkout or zip in the TTNImportDir:
+//
+//	{
+//	  "path": "lorawan-devices.zip", // in the TTNImportDir, the folder itself if empty
+//	  "vendor": "dragino",
+//	  "device": "lht65", // optional, all devices of the vendor if empty
+//	  "force": false // also import codecs that fail their self-tests
+//	}
+func PostImportTTN(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var body struct {
+		Path   string `json:"path"`
+		Vendor string `json:"vendor"`
+		Device string `json:"device"`
+		Force  bool   `json:"force"`
+	}
+	var fsys fs.FS
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/zip") {
+		query := req.URL.Query()
+		body.Vendor = query.Get("vendor")
+		body.Device = query.Get("device")
+		body.Force = query.Get("force") == "true"
+		file, err := os.CreateTemp("", "ttn-import-*.zip")
+		if err != nil {
+			serveError(resp, err)
+			return
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		n, err := io.Copy(file, io.LimitReader(req.Body, maxImportSize+1))
+		if err != nil {
+			http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if n > maxImportSize {
+			http.Error(resp, "request entity too large: max. 256MB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r, err := zip.NewReader(file, n)
+		if err != nil {
+			http.Error(resp, "bad request: zip: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		fsys = r
+	} else {
+		if err := unmarshalRequestBody(req, &body); err != nil {
+			http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := body.Path
+		if name == "" {
+			name = "."
+		}
+		if !fs.ValidPath(name) {
+			http.Error(resp, "bad request: path: must be a relative path in the import folder", http.StatusBadRequest)
+			return
+		}
+		dir := os.DirFS(TTNImportDir)
+		info, err := fs.Stat(dir, name)
+		if err != nil {
+			http.Error(resp, "bad request: path: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if info.IsDir() {
+			fsys, _ = fs.Sub(dir, name)
+		} else {
+			r, err := zip.OpenReader(filepath.Join(TTNImportDir, filepath.FromSlash(name)))
+			if err != nil {
+				http.Error(resp, "bad request: zip: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer r.Close()
+			fsys = r
+		}
+	}
+
+	result, err := ttn.Import(fsys, body.Vendor, body.Device, getRequestActor(req), body.Force)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	for _, codec := range result.Codecs {
+		if codec.Imported {
+			log.Printf("[INFO ] Codec imported: %s (self-tests passed: %v)", codec.ID, codec.Passed)
+		} else {
+			log.Printf("[WARN ] Codec not imported: %s failed its self-tests", codec.ID)
+		}
+	}
+
+	tools.SendJSON(resp, result)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/clouds"
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// GetDeviceActuatorCommands implements GET /devices/{deviceID}/actuators/{actuatorID}/commands
+func GetDeviceActuatorCommands(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	getActuatorCommands(resp, req, params.ByName("device_id"), params.ByName("actuator_id"))
+}
+
+// GetActuatorCommands implements GET /actuators/{actuatorID}/commands
+func GetActuatorCommands(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	getActuatorCommands(resp, req, edge.LocalID(), params.ByName("actuator_id"))
+}
+
+// PostDeviceActuatorCommands implements POST /devices/{deviceID}/actuators/{actuatorID}/commands
+func PostDeviceActuatorCommands(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	postActuatorCommand(resp, req, params.ByName("device_id"), params.ByName("actuator_id"))
+}
+
+// PostActuatorCommands implements POST /actuators/{actuatorID}/commands
+func PostActuatorCommands(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	postActuatorCommand(resp, req, edge.LocalID(), params.ByName("actuator_id"))
+}
+
+// GetDeviceActuatorCommand implements GET /devices/{deviceID}/actuators/{actuatorID}/commands/{commandID}
+func GetDeviceActuatorCommand(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	getActuatorCommand(resp, params.ByName("device_id"), params.ByName("actuator_id"), params.ByName("command_id"))
+}
+
+// GetActuatorCommand implements GET /actuators/{actuatorID}/commands/{commandID}
+func GetActuatorCommand(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	getActuatorCommand(resp, edge.LocalID(), params.ByName("actuator_id"), params.ByName("command_id"))
+}
+
+// PostDeviceActuatorCommand implements POST /devices/{deviceID}/actuators/{actuatorID}/commands/{commandID}
+func PostDeviceActuatorCommand(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	reportActuatorCommand(resp, req, params.ByName("device_id"), params.ByName("actuator_id"), params.ByName("command_id"))
+}
+
+// PostActuatorCommand implements POST /actuators/{actuatorID}/commands/{commandID}
+func PostActuatorCommand(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	reportActuatorCommand(resp, req, edge.LocalID(), params.ByName("actuator_id"), params.ByName("command_id"))
+}
+
+// PostDeviceCommands implements POST /devices/{deviceID}/commands
+//
+// The body is a list of reports from the device, like:
+//
+//	[{"actuator": "relay", "state": "acknowledged"}]
+//
+// Reports without an id apply to the oldest pending command of that actuator.
+func PostDeviceCommands(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	deviceID := params.ByName("device_id")
+
+	var reports []edge.CommandReport
+	if err := unmarshalRequestBody(req, &reports); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cmds := make([]*edge.Command, 0, len(reports))
+	for i := range reports {
+		cmd, err := edge.ReportCommand(deviceID, &reports[i])
+		if err != nil {
+			serveError(resp, err)
+			return
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	tools.SendJSON(resp, cmds)
+}
+
+////////////////////
+
+func getActuatorCommands(resp http.ResponseWriter, req *http.Request, deviceID string, actuatorID string) {
+
+	query := req.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	cmds := edge.GetCommands(deviceID, actuatorID, edge.CommandState(query.Get("state")), limit)
+	defer cmds.Close()
+	encoder := json.NewEncoder(resp)
+
+	cmd, err := cmds.Next()
+	if err != nil && err != io.EOF {
+		serveError(resp, err)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write([]byte{'['})
+	for cmd != nil {
+		encoder.Encode(cmd)
+		cmd, _ = cmds.Next()
+		if cmd != nil {
+			resp.Write([]byte{','})
+		}
+	}
+	resp.Write([]byte{']'})
+}
+
+func getActuatorCommand(resp http.ResponseWriter, deviceID string, actuatorID string, commandID string) {
+
+	cmd, err := edge.GetCommand(deviceID, actuatorID, commandID)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	tools.SendJSON(resp, cmd)
+}
+
+func postActuatorCommand(resp http.ResponseWriter, req *http.Request, deviceID string, actuatorID string) {
+
+	var body struct {
+		Value interface{} `json:"value"`
+		TTL   string      `json:"ttl"`
+	}
+	if err := unmarshalRequestBody(req, &body); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if body.TTL != "" {
+		var err error
+		ttl, err = edge.ParseDuration(body.TTL)
+		if err != nil {
+			http.Error(resp, "bad request: ttl: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	val := edge.NewValue(body.Value, time.Now())
+	cmd, meta, err := edge.PostCommand(deviceID, actuatorID, val, ttl)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[DB   ] Command %s queued for %s/%s.", cmd.ID.Hex(), deviceID, actuatorID)
+	clouds.FlagActuator(deviceID, actuatorID, clouds.ActionSync, val.Time, meta)
+
+	tools.SetRequestBody(req, cmd)
+	tools.SendJSON(resp, cmd)
+}
+
+func reportActuatorCommand(resp http.ResponseWriter, req *http.Request, deviceID string, actuatorID string, commandID string) {
+
+	var report edge.CommandReport
+	if err := unmarshalRequestBody(req, &report); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	report.ID = commandID
+	report.ActuatorID = actuatorID
+
+	cmd, err := edge.ReportCommand(deviceID, &report)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[DB   ] Command %s for %s/%s is %s.", cmd.ID.Hex(), deviceID, actuatorID, cmd.State)
+	tools.SendJSON(resp, cmd)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -33,9 +34,12 @@ type Device struct {
 func GetDevices(resp http.ResponseWriter, req *http.Request, params routing.Params) {
 
 	var query edge.Query
-	query.Parse(req.URL.Query())
+	if err := query.Parse(req.URL.Query()); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	devices := edge.GetDevices(&query)
-	encoder := json.NewEncoder(resp)
+	defer devices.Close()
 
 	device, err := devices.Next()
 	if err != nil && err.Error() != "EOF" {
@@ -44,15 +48,34 @@ func GetDevices(resp http.ResponseWriter, req *http.Request, params routing.Para
 	}
 
 	resp.Header().Set("Content-Type", "application/json")
-	resp.Write([]byte{'['})
+
+	// With a limit, the page is small enough to be buffered
+	// so that the cursor to the next page can be sent as header.
+	var w io.Writer = resp
+	var buf bytes.Buffer
+	if query.Limit != 0 {
+		w = &buf
+	}
+	encoder := json.NewEncoder(w)
+
+	n := int64(0)
+	w.Write([]byte{'['})
 	for device != nil {
 		encoder.Encode(device)
+		n++
 		device, _ = devices.Next()
 		if device != nil {
-			resp.Write([]byte{','})
+			w.Write([]byte{','})
+		}
+	}
+	w.Write([]byte{']'})
+
+	if query.Limit != 0 {
+		if n == query.Limit {
+			resp.Header().Set("X-Next-Cursor", devices.Cursor())
 		}
+		resp.Write(buf.Bytes())
 	}
-	resp.Write([]byte{']'})
 }
 
 func serveError(resp http.ResponseWriter, err error) {
@@ -205,11 +228,15 @@ func PostCurrentDeviceMeta(resp http.ResponseWriter, req *http.Request, params r
 func getDevice(resp http.ResponseWriter, req *http.Request, deviceID string) {
 
 	var buf bytes.Buffer
-	codec, err := edge.MarshalDevice(deviceID, req.Header, &buf)
+	codec, header, err := edge.MarshalDevice(deviceID, req.Header, &buf)
 	if err != nil {
 		serveError(resp, err)
 		return
 	}
+	// script codecs return the fPort of the LoRaWAN downlink
+	if fPort := header.Get("X-LoRaWAN-FPort"); fPort != "" {
+		resp.Header().Set("X-LoRaWAN-FPort", fPort)
+	}
 	resp.Header().Set("Content-Type", codec)
 	resp.Write(buf.Bytes())
 
@@ -239,13 +266,36 @@ func getDevice(resp http.ResponseWriter, req *http.Request, deviceID string) {
 
 func postDevices(resp http.ResponseWriter, req *http.Request) {
 
-	var device edge.Device
-	if err := unmarshalRequestBody(req, &device); err != nil {
+	body, err := tools.ReadAll(req.Body)
+	if err != nil {
 		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := edge.PostDevices(&device); err != nil {
+	device := &edge.Device{}
+	templateID := req.URL.Query().Get("template")
+	if templateID == "" || len(bytes.TrimSpace(body)) != 0 {
+		if err := json.Unmarshal(body, device); err != nil {
+			http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if templateID != "" {
+		tmpl, err := edge.GetTemplate(templateID)
+		if err != nil {
+			serveError(resp, err)
+			return
+		}
+		meta, tags := device.Meta, device.Tags
+		device = tmpl.NewDevice(device.ID, device.Name)
+		for key, value := range meta {
+			device.Meta[key] = value
+		}
+		device.Tags = append(device.Tags, tags...)
+	}
+
+	if err := edge.PostDevices(device); err != nil {
 		serveError(resp, err)
 		return
 	}
@@ -254,6 +304,10 @@ func postDevices(resp http.ResponseWriter, req *http.Request) {
 
 	clouds.FlagDevice(device.ID, clouds.ActionCreate, device.Meta)
 
+	if templateID != "" {
+		tools.SetRequestBody(req, device)
+	}
+
 	encoder := json.NewEncoder(resp)
 	resp.Header().Set("Content-Type", "application/json")
 	encoder.Encode(device.ID)
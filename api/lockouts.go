@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// GetLockouts implements GET /lockouts
+//
+// Lists the usernames ("user:{username}") and IP addresses ("ip:{address}") with recent failed logins.
+func GetLockouts(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	tools.SendJSON(resp, edge.GetLockouts())
+}
+
+// DeleteLockouts implements DELETE /lockouts
+//
+// Clears all failed logins, so all locked usernames and IP addresses can log in again.
+func DeleteLockouts(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	tools.SendJSON(resp, edge.DeleteLockouts())
+}
+
+// DeleteLockout implements DELETE /lockouts/{lockout_id}
+func DeleteLockout(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	if err := edge.DeleteLockout(params.ByName("lockout_id")); err != nil {
+		serveError(resp, err)
+		return
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// MQTTLogin is the identity of a MQTT client, checked once with the CONNECT packet.
+// The permissions are checked for every publish.
+type MQTTLogin struct {
+	// UserID is set for clients that logged in with a token or username and password.
+	UserID string
+	// SessionID is the session of the token, if the client logged in with a token.
+	SessionID string
+	// APIKeyID is set for clients that logged in with an API key.
+	APIKeyID string
+	// CloudID is set for messages of the cloud synchronization (downstream).
+	CloudID string
+	// IP is the address of the client, if known.
+	// Clients without credentials get the role of the trust policy for that address.
+	IP net.IP
+}
+
+// MQTTConnect checks the credentials of a MQTT CONNECT packet:
+// an API key or token as password (with any or no username), or username and password.
+// Clients without credentials are accepted from trusted networks and apps only.
+func MQTTConnect(username string, password string, addr net.Addr) (*MQTTLogin, error) {
+
+	login := &MQTTLogin{IP: addrIP(addr)}
+
+	if username == "" && password == "" {
+		if role := loginTrustedRole(login); role == "" {
+			return nil, fmt.Errorf("credentials required")
+		}
+		return login, nil
+	}
+
+	if strings.HasPrefix(password, edge.APIKeyPrefix) {
+		apiKey, err := edge.CheckAPIKey(password)
+		if err != nil {
+			return nil, err
+		}
+		login.APIKeyID = apiKey.ID
+		return login, nil
+	}
+
+	if username == "" {
+		token, err := CheckToken(password)
+		if err != nil {
+			return nil, err
+		}
+		claims := token.Claims.(jwt.MapClaims)
+		login.UserID, _ = claims["client"].(string)
+		login.SessionID, _ = claims["sid"].(string)
+		return login, nil
+	}
+
+	addrStr := ""
+	if login.IP != nil {
+		addrStr = login.IP.String()
+	}
+	if wait := edge.CheckLogin(username, addrStr); wait > 0 {
+		return nil, fmt.Errorf("too many failed logins, retry in %s", wait)
+	}
+	user, err := edge.CheckUserCredentials(username, password)
+	if err != nil {
+		edge.LoginFailed(username, addrStr)
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		// the TOTP code can not be sent with CONNECT, so these users must use a token
+		return nil, fmt.Errorf("TOTP enabled: use a token as password")
+	}
+	edge.LoginSucceeded(username, addrStr)
+	login.UserID = user.ID
+	return login, nil
+}
+
+// HTTPLogin returns the login of a (authorized) HTTP request, used for MQTT over WebSocket
+// if the CONNECT packet has no credentials.
+func HTTPLogin(req *http.Request) *MQTTLogin {
+
+	login := &MQTTLogin{IP: requestIP(req)}
+	if reqToken := getRequestToken(req); strings.HasPrefix(reqToken, edge.APIKeyPrefix) {
+		if apiKey, err := edge.CheckAPIKey(reqToken); err == nil {
+			login.APIKeyID = apiKey.ID
+			return login
+		}
+	} else if reqToken != "" {
+		if token, err := CheckToken(reqToken); err == nil {
+			claims := token.Claims.(jwt.MapClaims)
+			login.UserID, _ = claims["client"].(string)
+			login.SessionID, _ = claims["sid"].(string)
+			return login
+		}
+	}
+	if loginTrustedRole(login) == "" {
+		return nil
+	}
+	return login
+}
+
+// loginTrustedRole returns the role of the trust policy for a login without credentials.
+// The policy is checked again for every publish, so changes apply to connected clients.
+func loginTrustedRole(login *MQTTLogin) string {
+	if login.IP == nil {
+		return ""
+	}
+	role, _ := trustedRole(login.IP)
+	return role
+}
+
+func addrIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+type mqttLoginContextKey struct{}
+
+// WithMQTTLogin returns the request (of a MQTT publish) with the login of the client that sent it.
+func WithMQTTLogin(req *http.Request, login *MQTTLogin) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), mqttLoginContextKey{}, login))
+}
+
+// requestMQTTLogin returns the login of the MQTT client of the request, or nil.
+func requestMQTTLogin(req *http.Request) *MQTTLogin {
+	login, _ := req.Context().Value(mqttLoginContextKey{}).(*MQTTLogin)
+	return login
+}
+
+// mqttResources are the resources that match subscriptions with a wildcard as first level.
+var mqttResources = []string{"devices", "codecs", "apps", "clouds", "users", "sys"}
+
+// MQTTSubscribeAllowed checks if the MQTT client may subscribe to the topic (filter),
+// which needs the read permission for the topic like a GET request.
+// Wildcards as first level ("#" or "+/...") need the read permission for all resources.
+func MQTTSubscribeAllowed(login *MQTTLogin, topic string) bool {
+
+	paths := []string{topic}
+	if first := strings.SplitN(topic, "/", 2)[0]; first == "#" || first == "+" {
+		paths = paths[:0]
+		for _, resource := range mqttResources {
+			paths = append(paths, resource+strings.TrimPrefix(topic, first))
+		}
+	}
+	for _, path := range paths {
+		req := &http.Request{
+			Method: http.MethodGet,
+			URL:    &url.URL{Path: "/" + path},
+			Header: http.Header{"X-Proto": []string{"mqtt"}},
+		}
+		if !checkMQTTPermission(&discardResponse{}, WithMQTTLogin(req, login)) {
+			return false
+		}
+	}
+	return true
+}
+
+// discardResponse is the response of permission checks that are not sent to the client.
+type discardResponse struct {
+	header http.Header
+}
+
+func (resp *discardResponse) Header() http.Header {
+	if resp.header == nil {
+		resp.header = make(http.Header)
+	}
+	return resp.header
+}
+
+func (resp *discardResponse) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (resp *discardResponse) WriteHeader(statusCode int) {}
+
+// checkMQTTPermission checks if the MQTT client may do that publish (or subscription, see MQTTSubscribeAllowed).
+func checkMQTTPermission(resp http.ResponseWriter, req *http.Request) bool {
+
+	login := requestMQTTLogin(req)
+	if login == nil {
+		log.Printf("[ERR  ] Auth: MQTT %s without login", req.URL.Path)
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+
+	if login.CloudID != "" {
+		// the cloud synchronization is set up by admins
+		return true
+	}
+
+	if login.APIKeyID != "" {
+		// the key is loaded again, so revoked keys can not be used anymore
+		apiKey, err := edge.GetAPIKey(login.APIKeyID)
+		if err != nil {
+			log.Printf("[ERR  ] Auth: MQTT api key %q: %v", login.APIKeyID, err)
+			http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return false
+		}
+		return checkAPIKeyPermission(resp, req, apiKey)
+	}
+
+	if login.UserID != "" {
+		if login.SessionID != "" {
+			if _, err := edge.CheckSession(login.SessionID); err != nil {
+				log.Printf("[ERR  ] Auth: MQTT session: %s", err.Error())
+				http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return false
+			}
+		}
+		return checkPermission(resp, req, login.UserID)
+	}
+
+	role := loginTrustedRole(login)
+	if role == "" {
+		log.Printf("[WARN ] Auth: MQTT client %s is not trusted anymore", login.IP)
+		http.Error(resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+	permission := requiredPermission(req)
+	if permission != "" && !edge.HasPermission(role, permission) {
+		log.Printf("[WARN ] Auth: MQTT client %s (%s) has no permission %q for %s", login.IP, role, permission, req.URL.Path)
+		http.Error(resp, "forbidden: missing permission "+permission, http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,591 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	jwt "github.com/dgrijalva/jwt-go"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// oidcLoginTimeout is the time to complete the login at the identity provider.
+const oidcLoginTimeout = 10 * time.Minute
+
+// oidcLeeway is the allowed clock difference to the identity provider.
+const oidcLeeway = time.Minute
+
+// oidcJWKSRefresh is the minimum time between two JWKS downloads for unknown key IDs.
+const oidcJWKSRefresh = time.Minute
+
+var oidcClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcProvider is the discovered configuration of the identity provider.
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	keys        map[string]interface{}
+	keysUpdated time.Time
+	mutex       sync.Mutex
+}
+
+var oidcProviders = map[string]*oidcProvider{}
+var oidcProvidersMutex sync.Mutex
+
+// getOIDCProvider returns the (cached) configuration of the identity provider.
+func getOIDCProvider(issuer string) (*oidcProvider, error) {
+
+	oidcProvidersMutex.Lock()
+	defer oidcProvidersMutex.Unlock()
+
+	if provider, ok := oidcProviders[issuer]; ok {
+		return provider, nil
+	}
+
+	var provider oidcProvider
+	if err := oidcGetJSON(issuer+"/.well-known/openid-configuration", &provider); err != nil {
+		return nil, err
+	}
+	if provider.Issuer != issuer {
+		return nil, fmt.Errorf("the issuer %q does not match the configured issuer %q", provider.Issuer, issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete openid-configuration of %q", issuer)
+	}
+	oidcProviders[issuer] = &provider
+	return &provider, nil
+}
+
+// key returns the public key of the identity provider to verify tokens with.
+func (provider *oidcProvider) key(keyID string) (interface{}, error) {
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key, ok := provider.keys[keyID]; ok {
+		return key, nil
+	}
+	if time.Since(provider.keysUpdated) < oidcJWKSRefresh {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	provider.keysUpdated = time.Now()
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := oidcGetJSON(provider.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	provider.keys = make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("[WARN ] OIDC: key %q of %s: %v", jwk.KeyID, provider.Issuer, err)
+			continue
+		}
+		provider.keys[jwk.KeyID] = key
+	}
+	if key, ok := provider.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", keyID)
+}
+
+// jsonWebKey is a RSA or EC public key (RFC 7517).
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+}
+
+func oidcGetJSON(url string, v interface{}) error {
+	resp, err := oidcClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// oidcLogin is a login that has been started at /auth/oidc/login.
+type oidcLogin struct {
+	Nonce       string
+	Verifier    string
+	RedirectURI string
+	// Return is the (local) path to go to after the login.
+	Return  string
+	Expires time.Time
+}
+
+// maxOIDCLogins is the max. number of pending logins.
+// GET /auth/oidc/login needs no authentication, so each request must not add memory without limit.
+const maxOIDCLogins = 1000
+
+var oidcLogins = map[string]*oidcLogin{}
+var oidcLoginsMutex sync.Mutex
+
+// addOIDCLogin stores a pending login, after removing the expired ones.
+// It returns false if there are too many pending logins.
+func addOIDCLogin(state string, login *oidcLogin) bool {
+
+	oidcLoginsMutex.Lock()
+	defer oidcLoginsMutex.Unlock()
+
+	now := time.Now()
+	for s, l := range oidcLogins {
+		if now.After(l.Expires) {
+			delete(oidcLogins, s)
+		}
+	}
+	if len(oidcLogins) >= maxOIDCLogins {
+		return false
+	}
+	oidcLogins[state] = login
+	return true
+}
+
+// isLocalPath reports whether ret is a path on this gateway.
+// Browsers read a backslash like a slash, so /\evil.example is another site.
+func isLocalPath(ret string) bool {
+	if !strings.HasPrefix(ret, "/") || strings.HasPrefix(ret, "//") || strings.Contains(ret, "\\") {
+		return false
+	}
+	u, err := url.Parse(ret)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" && u.Host == "" && u.User == nil && strings.HasPrefix(u.Path, "/")
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[ERR  ] OIDC: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// oidcRedirectURI returns the URL of /auth/oidc/callback for the request.
+func oidcRedirectURI(config *edge.OIDCConfig, req *http.Request) string {
+	if config.RedirectURL != "" {
+		return config.RedirectURL
+	}
+	scheme := "http"
+	if req.Header.Get("X-Secure") == "true" {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + "/auth/oidc/callback"
+}
+
+// OIDCInfo tells the login page if the OIDC login is available.
+type OIDCInfo struct {
+	Enabled bool   `json:"enabled"`
+	Name    string `json:"name"`
+}
+
+// GetOIDCInfo implements GET /auth/oidc
+func GetOIDCInfo(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	config, err := edge.GetOIDCConfig()
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	tools.SendJSON(resp, OIDCInfo{
+		Enabled: config.Enabled,
+		Name:    config.Name,
+	})
+}
+
+// GetOIDCLogin implements GET /auth/oidc/login?return={path}
+//
+// Redirects to the login page of the identity provider (authorization code flow with PKCE).
+func GetOIDCLogin(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	config, err := edge.GetOIDCConfig()
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	if !config.Enabled {
+		http.Error(resp, "OIDC login is not enabled", http.StatusNotFound)
+		return
+	}
+
+	provider, err := getOIDCProvider(config.Issuer)
+	if err != nil {
+		log.Printf("[ERR  ] OIDC: %v", err)
+		http.Error(resp, "the identity provider is not available, use the local login", http.StatusBadGateway)
+		return
+	}
+
+	// only local paths, so the login can not redirect to other sites
+	ret := req.URL.Query().Get("return")
+	if !isLocalPath(ret) {
+		ret = "/"
+	}
+
+	state := randomString()
+	login := &oidcLogin{
+		Nonce:       randomString(),
+		Verifier:    randomString(),
+		RedirectURI: oidcRedirectURI(config, req),
+		Return:      ret,
+		Expires:     time.Now().Add(oidcLoginTimeout),
+	}
+
+	if !addOIDCLogin(state, login) {
+		log.Printf("[WARN ] OIDC: too many pending logins.")
+		http.Error(resp, "too many pending logins, please try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", login.RedirectURI)
+	query.Set("scope", strings.Join(append([]string{"openid"}, config.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", login.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	http.Redirect(resp, req, provider.AuthorizationEndpoint+sep+query.Encode(), http.StatusFound)
+}
+
+// GetOIDCCallback implements GET /auth/oidc/callback
+//
+// The identity provider redirects here after the login. The code is exchanged for an ID token,
+// the user is created or updated and gets the usual edge token (as cookie).
+func GetOIDCCallback(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	query := req.URL.Query()
+	if e := query.Get("error"); e != "" {
+		log.Printf("[ERR  ] OIDC: login failed: %s %s", e, query.Get("error_description"))
+		http.Error(resp, "login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	oidcLoginsMutex.Lock()
+	login := oidcLogins[query.Get("state")]
+	delete(oidcLogins, query.Get("state"))
+	oidcLoginsMutex.Unlock()
+	if login == nil || time.Now().After(login.Expires) {
+		http.Error(resp, "login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	config, err := edge.GetOIDCConfig()
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	if !config.Enabled {
+		http.Error(resp, "OIDC login is not enabled", http.StatusNotFound)
+		return
+	}
+	provider, err := getOIDCProvider(config.Issuer)
+	if err != nil {
+		log.Printf("[ERR  ] OIDC: %v", err)
+		http.Error(resp, "the identity provider is not available, use the local login", http.StatusBadGateway)
+		return
+	}
+
+	idToken, err := oidcExchangeCode(config, provider, query.Get("code"), login)
+	if err != nil {
+		log.Printf("[ERR  ] OIDC: %v", err)
+		http.Error(resp, "login failed: can not get the id token", http.StatusBadGateway)
+		return
+	}
+
+	claims, err := oidcVerifyToken(config, provider, idToken, login.Nonce)
+	if err != nil {
+		log.Printf("[ERR  ] OIDC: invalid id token: %v", err)
+		http.Error(resp, "login failed: invalid id token", http.StatusUnauthorized)
+		return
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		http.Error(resp, "login failed: the id token has no subject", http.StatusUnauthorized)
+		return
+	}
+	username, _ := claims[config.UsernameClaim].(string)
+	if username == "" {
+		username, _ = claims["email"].(string)
+	}
+	if username == "" {
+		username = subject
+	}
+	name, _ := claims["name"].(string)
+
+	role := config.MapRole(claimValues(claims[config.RoleClaim]))
+	if role == "" {
+		log.Printf("[WARN ] OIDC: user %q has no role at this gateway.", username)
+		http.Error(resp, "forbidden: no role for this gateway", http.StatusForbidden)
+		return
+	}
+
+	user, err := edge.UpsertOIDCUser(subject, username, name, role)
+	if err != nil {
+		log.Printf("[ERR  ] OIDC: user %q: %v", username, err)
+		serveError(resp, err)
+		return
+	}
+
+	session := edge.Session{
+		UserID:    user.ID,
+		Addr:      req.RemoteAddr,
+		UserAgent: req.UserAgent(),
+	}
+	if err := edge.PostSession(&session); err != nil {
+		serveError(resp, err)
+		return
+	}
+	tokenString, err := generateToken(user.ID, session.ID)
+	if err != nil {
+		log.Printf("[ERR  ] OIDC: %s", err.Error())
+		http.Error(resp, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO ] OIDC: user %q (%s) logged in.", user.Username, role)
+
+	cookie := http.Cookie{
+		Name:     "Token",
+		Value:    tokenString,
+		Path:     "/",
+		Expires:  time.Now().Add(time.Minute * tokenExpTimeMinutes),
+		HttpOnly: true,
+		MaxAge:   60 * tokenExpTimeMinutes,
+		SameSite: http.SameSiteLaxMode, // the request comes from the identity provider
+	}
+	http.SetCookie(resp, &cookie)
+	http.Redirect(resp, req, login.Return, http.StatusFound)
+}
+
+// oidcExchangeCode exchanges the authorization code for the ID token at the token endpoint.
+func oidcExchangeCode(config *edge.OIDCConfig, provider *oidcProvider, code string, login *oidcLogin) (string, error) {
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", login.RedirectURI)
+	form.Set("client_id", config.ClientID)
+	form.Set("code_verifier", login.Verifier)
+
+	req, err := http.NewRequest(http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: %s: %s", resp.Status, body)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return "", err
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("token endpoint: no id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+// oidcVerifyToken checks the signature, issuer, audience, expiry and nonce of the ID token.
+func oidcVerifyToken(config *edge.OIDCConfig, provider *oidcProvider, idToken string, nonce string) (jwt.MapClaims, error) {
+
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unsupported signing method %v", token.Header["alg"])
+		}
+		keyID, _ := token.Header["kid"].(string)
+		return provider.key(keyID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+
+	if iss, _ := claims["iss"].(string); iss != provider.Issuer {
+		return nil, fmt.Errorf("wrong issuer %q", iss)
+	}
+	audience := false
+	for _, aud := range claimValues(claims["aud"]) {
+		if aud == config.ClientID {
+			audience = true
+		}
+	}
+	if !audience {
+		return nil, fmt.Errorf("wrong audience %v", claims["aud"])
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcLeeway)) {
+		return nil, fmt.Errorf("expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("wrong nonce")
+	}
+	return claims, nil
+}
+
+// claimValues returns the values of a string or list claim.
+func claimValues(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// GetOIDCConfig implements GET /sys/oidc
+//
+// The client secret is not returned.
+func GetOIDCConfig(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	config, err := edge.GetOIDCConfig()
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	c := *config
+	c.ClientSecret = ""
+	tools.SendJSON(resp, c)
+}
+
+// PostOIDCConfig implements POST /sys/oidc
+//
+// An empty clientSecret keeps the current secret.
+func PostOIDCConfig(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var config edge.OIDCConfig
+	if err := unmarshalRequestBody(req, &config); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := edge.SetOIDCConfig(&config); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	oidcProvidersMutex.Lock()
+	delete(oidcProviders, config.Issuer)
+	oidcProvidersMutex.Unlock()
+
+	log.Printf("[INFO ] OIDC config changed by %s.", getRequestActor(req))
+
+	// the request body is published with MQTT, so remove the secret
+	config.ClientSecret = ""
+	tools.SetRequestBody(req, &config)
+}
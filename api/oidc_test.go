@@ -0,0 +1,274 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// mockIssuer is an identity provider with discovery, JWKS and token endpoint.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// issuer is the issuer of the openid-configuration, the server URL by default.
+	issuer string
+	// idToken is returned for the code "good-code".
+	idToken string
+	// verifier is the last code_verifier sent to the token endpoint.
+	verifier string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(resp http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(resp).Encode(map[string]string{
+			"issuer":                 m.issuer,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(resp http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(resp).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(resp http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		m.verifier = req.Form.Get("code_verifier")
+		if req.Form.Get("grant_type") != "authorization_code" || req.Form.Get("code") != "good-code" {
+			http.Error(resp, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(resp).Encode(map[string]string{"id_token": m.idToken})
+	})
+	m.Server = httptest.NewServer(mux)
+	m.issuer = m.URL
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockIssuer) sign(t *testing.T, key *rsa.PrivateKey, keyID string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	str, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return str
+}
+
+func (m *mockIssuer) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   m.URL,
+		"aud":   "wazigate",
+		"sub":   "user-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": "nonce-1",
+	}
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+
+	m := newMockIssuer(t)
+	provider, err := getOIDCProvider(m.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.TokenEndpoint != m.URL+"/token" || provider.JWKSURI != m.URL+"/jwks" {
+		t.Errorf("wrong endpoints: %+v", provider)
+	}
+
+	other := newMockIssuer(t)
+	other.issuer = "https://evil.example.com"
+	if _, err := getOIDCProvider(other.URL); err == nil {
+		t.Errorf("no error for a wrong issuer in the openid-configuration")
+	}
+}
+
+func TestOIDCExchangeCode(t *testing.T) {
+
+	m := newMockIssuer(t)
+	m.idToken = "the-id-token"
+	provider, err := getOIDCProvider(m.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &edge.OIDCConfig{ClientID: "wazigate", ClientSecret: "secret"}
+	login := &oidcLogin{Verifier: "verifier-1", RedirectURI: "http://gateway/auth/oidc/callback"}
+
+	idToken, err := oidcExchangeCode(config, provider, "good-code", login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idToken != "the-id-token" {
+		t.Errorf("got id token %q", idToken)
+	}
+	if m.verifier != "verifier-1" {
+		t.Errorf("the code_verifier %q was not sent", login.Verifier)
+	}
+
+	if _, err := oidcExchangeCode(config, provider, "bad-code", login); err == nil {
+		t.Errorf("no error for a bad code")
+	}
+}
+
+func TestOIDCVerifyToken(t *testing.T) {
+
+	m := newMockIssuer(t)
+	provider, err := getOIDCProvider(m.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &edge.OIDCConfig{ClientID: "wazigate"}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		keyID  string
+		change func(jwt.MapClaims)
+		valid  bool
+	}{
+		{"valid", m.key, "k1", func(c jwt.MapClaims) {}, true},
+		{"audience list", m.key, "k1", func(c jwt.MapClaims) { c["aud"] = []string{"other", "wazigate"} }, true},
+		{"wrong nonce", m.key, "k1", func(c jwt.MapClaims) { c["nonce"] = "nonce-2" }, false},
+		{"no nonce", m.key, "k1", func(c jwt.MapClaims) { delete(c, "nonce") }, false},
+		{"wrong audience", m.key, "k1", func(c jwt.MapClaims) { c["aud"] = "other" }, false},
+		{"wrong issuer", m.key, "k1", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, false},
+		{"expired", m.key, "k1", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, false},
+		{"no expiry", m.key, "k1", func(c jwt.MapClaims) { delete(c, "exp") }, false},
+		{"other key", otherKey, "k1", func(c jwt.MapClaims) {}, false},
+		{"unknown key id", m.key, "k2", func(c jwt.MapClaims) {}, false},
+	}
+
+	for _, test := range tests {
+		claims := m.claims()
+		test.change(claims)
+		idToken := m.sign(t, test.key, test.keyID, claims)
+		_, err := oidcVerifyToken(config, provider, idToken, "nonce-1")
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+
+	// a HMAC token signed with the public key must not be accepted
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, m.claims())
+	token.Header["kid"] = "k1"
+	idToken, _ := token.SignedString(m.key.N.Bytes())
+	if _, err := oidcVerifyToken(config, provider, idToken, "nonce-1"); err == nil {
+		t.Errorf("HS256: no error")
+	}
+}
+
+func TestOIDCMapRole(t *testing.T) {
+
+	config := &edge.OIDCConfig{
+		RoleMapping: map[string]string{
+			"gateway-admins": edge.RoleAdmin,
+			"staff":          edge.RoleViewer,
+		},
+	}
+
+	tests := []struct {
+		claim       interface{}
+		defaultRole string
+		role        string
+	}{
+		{[]interface{}{"staff", "gateway-admins"}, "", edge.RoleAdmin},
+		{"staff", "", edge.RoleViewer},
+		{[]interface{}{"others"}, "", ""},
+		{[]interface{}{"others"}, edge.RoleViewer, edge.RoleViewer},
+		{nil, "", ""},
+	}
+
+	for _, test := range tests {
+		config.DefaultRole = test.defaultRole
+		if role := config.MapRole(claimValues(test.claim)); role != test.role {
+			t.Errorf("%v (default %q): got role %q, want %q", test.claim, test.defaultRole, role, test.role)
+		}
+	}
+}
+
+func TestAddOIDCLogin(t *testing.T) {
+
+	defer func() {
+		oidcLogins = map[string]*oidcLogin{}
+	}()
+
+	expired := &oidcLogin{Expires: time.Now().Add(-time.Minute)}
+	pending := &oidcLogin{Expires: time.Now().Add(oidcLoginTimeout)}
+
+	for i := 0; i < maxOIDCLogins; i++ {
+		if !addOIDCLogin(randomString(), pending) {
+			t.Fatalf("login %d rejected", i)
+		}
+	}
+	if addOIDCLogin("one-more", pending) {
+		t.Errorf("more than %d pending logins", maxOIDCLogins)
+	}
+
+	oidcLogins = map[string]*oidcLogin{}
+	for i := 0; i < maxOIDCLogins; i++ {
+		oidcLogins[randomString()] = expired
+	}
+	if !addOIDCLogin("new", pending) {
+		t.Errorf("expired logins were not removed")
+	}
+	if len(oidcLogins) != 1 {
+		t.Errorf("%d pending logins, want 1", len(oidcLogins))
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+
+	tests := []struct {
+		ret   string
+		local bool
+	}{
+		{"/", true},
+		{"/#/devices", true},
+		{"/apps/waziup.wazigate-system/?tab=1", true},
+		{"", false},
+		{"devices", false},
+		{"//evil.example", false},
+		{"/\\evil.example", false},
+		{"\\/evil.example", false},
+		{"/\\/evil.example", false},
+		{"https://evil.example/", false},
+		{"/\t/evil.example", false},
+		{"/\n/evil.example", false},
+	}
+
+	for _, test := range tests {
+		if local := isLocalPath(test.ret); local != test.local {
+			t.Errorf("%q: got %v, want %v", test.ret, local, test.local)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	jwt "github.com/dgrijalva/jwt-go"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// Session is a login session, as returned by GET /auth/sessions.
+type Session struct {
+	edge.Session
+	// Current is true for the session of the request.
+	Current bool `json:"current"`
+}
+
+// getRequestSessionID returns the session ID of the request token.
+func getRequestSessionID(req *http.Request) string {
+	token, err := CheckToken(getRequestToken(req))
+	if err != nil {
+		return ""
+	}
+	sessionID, _ := token.Claims.(jwt.MapClaims)["sid"].(string)
+	return sessionID
+}
+
+// GetSessions implements GET /auth/sessions
+//
+// Lists the active login sessions of the user.
+func GetSessions(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	sessions, err := edge.GetSessions(user.ID)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	current := getRequestSessionID(req)
+	list := make([]Session, len(sessions))
+	for i, session := range sessions {
+		list[i] = Session{
+			Session: session,
+			Current: session.ID == current,
+		}
+	}
+	tools.SendJSON(resp, list)
+}
+
+// DeleteSessions implements DELETE /auth/sessions
+//
+// Logs out all sessions of the user, including the current one.
+func DeleteSessions(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	n, err := edge.DeleteSessions(user.ID)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	tools.SendJSON(resp, n)
+}
+
+// DeleteSession implements DELETE /auth/sessions/{session_id}
+func DeleteSession(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	if err := edge.DeleteSession(user.ID, params.ByName("session_id")); err != nil {
+		serveError(resp, err)
+		return
+	}
+}
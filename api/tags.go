@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Waziup/wazigate-edge/clouds"
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// GetTags implements GET /tags
+func GetTags(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	tags, err := edge.GetTags()
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	tools.SendJSON(resp, tags)
+}
+
+// GetDeviceTags implements GET /devices/{deviceID}/tags
+func GetDeviceTags(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	device, err := edge.GetDevice(params.ByName("device_id"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	if device.Tags == nil {
+		device.Tags = []string{}
+	}
+
+	tools.SendJSON(resp, device.Tags)
+}
+
+// PostDeviceTags implements POST /devices/{deviceID}/tags
+func PostDeviceTags(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	deviceID := params.ByName("device_id")
+
+	var tags []string
+	if err := unmarshalRequestBody(req, &tags); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := edge.SetDeviceTags(deviceID, tags); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[DB   ] Device %s tags changed: %v", deviceID, tags)
+}
+
+// GetTagValues implements GET /tags/{tag}/values
+func GetTagValues(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	values, err := edge.GetTagValues(params.ByName("tag"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	tools.SendJSON(resp, values)
+}
+
+// PostTagMeta implements POST /tags/{tag}/meta
+func PostTagMeta(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var meta edge.Meta
+	if err := unmarshalRequestBody(req, &meta); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setTagMeta(resp, params.ByName("tag"), meta)
+}
+
+// PostTagCodec implements POST /tags/{tag}/codec
+func PostTagCodec(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	body, err := tools.ReadAll(req.Body)
+	if err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var codecID string
+	contentType := req.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") {
+		err = json.Unmarshal(body, &codecID)
+		if err != nil {
+			http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		codecID = string(body)
+	}
+
+	if _, err := edge.GetCodec(codecID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	setTagMeta(resp, params.ByName("tag"), edge.Meta{"codec": codecID})
+}
+
+// DeleteTagDevices implements DELETE /tags/{tag}/devices
+func DeleteTagDevices(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	tag := params.ByName("tag")
+	ids, err := edge.GetTagDeviceIDs(tag)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	deleted := make([]string, 0, len(ids))
+	for _, deviceID := range ids {
+		_, numS, numA, err := edge.DeleteDevice(deviceID)
+		if err != nil {
+			log.Printf("[ERR  ] Can not remove device %s: %v", deviceID, err)
+			continue
+		}
+		log.Printf("[DB   ] Removed device %s (%d sensor values, %d actuator values).", deviceID, numS, numA)
+		clouds.FlagDevice(deviceID, clouds.ActionDelete, nil)
+		deleted = append(deleted, deviceID)
+	}
+
+	tools.SendJSON(resp, deleted)
+}
+
+////////////////////
+
+func setTagMeta(resp http.ResponseWriter, tag string, meta edge.Meta) {
+
+	ids, err := edge.SetTagMeta(tag, meta)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	for _, deviceID := range ids {
+		clouds.FlagDevice(deviceID, clouds.ActionModify, meta)
+	}
+
+	log.Printf("[DB   ] Tag %q meta changed on %d devices: %v", tag, len(ids), meta)
+	tools.SendJSON(resp, ids)
+}
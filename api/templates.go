@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Waziup/wazigate-edge/clouds"
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// GetTemplates implements GET /templates
+func GetTemplates(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	templates := edge.GetTemplates()
+	encoder := json.NewEncoder(resp)
+
+	tmpl, err := templates.Next()
+	if err != nil && err != io.EOF {
+		serveError(resp, err)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write([]byte{'['})
+	for tmpl != nil {
+		encoder.Encode(tmpl)
+		tmpl, _ = templates.Next()
+		if tmpl != nil {
+			resp.Write([]byte{','})
+		}
+	}
+	resp.Write([]byte{']'})
+	templates.Close()
+}
+
+// GetTemplate implements GET /templates/{templateID}
+func GetTemplate(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	tmpl, err := edge.GetTemplate(params.ByName("template_id"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	tools.SendJSON(resp, tmpl)
+}
+
+// PostTemplates implements POST /templates
+func PostTemplates(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	postTemplate(resp, req, "")
+}
+
+// PostTemplate implements POST /templates/{templateID}
+func PostTemplate(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	postTemplate(resp, req, params.ByName("template_id"))
+}
+
+// DeleteTemplate implements DELETE /templates/{templateID}
+func DeleteTemplate(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	templateID := params.ByName("template_id")
+	if err := edge.DeleteTemplate(templateID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[DB   ] Template deleted: %s", templateID)
+}
+
+// PostTemplateDevices implements POST /templates/{templateID}/devices
+//
+// The body is a CSV list of device IDs and names, one device per line:
+//
+//	id,name
+//	soil-01,Soil Station 1
+//	soil-02,Soil Station 2
+//
+// The header line is optional. An empty ID will create a random one.
+func PostTemplateDevices(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	tmpl, err := edge.GetTemplate(params.ByName("template_id"))
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	body, err := tools.ReadAll(req.Body)
+	if err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(records) != 0 && len(records[0]) != 0 && strings.EqualFold(records[0][0], "id") {
+		records = records[1:]
+	}
+
+	type result struct {
+		ID    string `json:"id"`
+		Error string `json:"error,omitempty"`
+	}
+	results := make([]result, 0, len(records))
+	devices := make([]*edge.Device, 0, len(records))
+
+	for _, record := range records {
+		var deviceID, name string
+		if len(record) > 0 {
+			deviceID = strings.TrimSpace(record[0])
+		}
+		if len(record) > 1 {
+			name = strings.TrimSpace(record[1])
+		}
+		device := tmpl.NewDevice(deviceID, name)
+		if err := edge.PostDevices(device); err != nil {
+			results = append(results, result{ID: deviceID, Error: err.Error()})
+			continue
+		}
+		clouds.FlagDevice(device.ID, clouds.ActionCreate, device.Meta)
+		results = append(results, result{ID: device.ID})
+		devices = append(devices, device)
+	}
+
+	log.Printf("[DB   ] Created %d devices from template %s.", len(devices), tmpl.ID)
+
+	tools.SetRequestBody(req, devices)
+	tools.SendJSON(resp, results)
+}
+
+////////////////////
+
+func postTemplate(resp http.ResponseWriter, req *http.Request, templateID string) {
+
+	var tmpl edge.DeviceTemplate
+	if err := unmarshalRequestBody(req, &tmpl); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if templateID != "" {
+		tmpl.ID = templateID
+	}
+
+	if err := edge.PostTemplate(&tmpl); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[DB   ] Template upsert: %s", tmpl.ID)
+
+	tools.SetRequestBody(req, &tmpl)
+
+	resp.Header().Set("Content-Type", "application/json")
+	data, _ := json.Marshal(tmpl.ID)
+	resp.Write(data)
+}
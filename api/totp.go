@@ -0,0 +1,103 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// PostTOTP implements POST /auth/totp
+//
+// Starts the TOTP enrolment of the user. The response has the secret and the
+// otpauth:// URI for authenticator apps. Confirm with POST /auth/totp/confirm.
+func PostTOTP(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	enrolment, err := edge.BeginTOTP(user.ID)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	tools.SendJSON(resp, enrolment)
+}
+
+// PostTOTPConfirm implements POST /auth/totp/confirm
+//
+// Enables TOTP with the first code from the authenticator app:
+//
+//	{"code": "123456"}
+//
+// The response are the recovery codes, that can be used once each instead of a TOTP code.
+func PostTOTPConfirm(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := unmarshalRequestBody(req, &body); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codes, err := edge.ConfirmTOTP(user.ID, body.Code)
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] User %q enabled TOTP.", user.Username)
+
+	// the request body is published with MQTT, so remove the code
+	tools.SetRequestBody(req, struct{}{})
+	tools.SendJSON(resp, codes)
+}
+
+// DeleteTOTP implements DELETE /auth/totp?code={code}
+//
+// Disables TOTP for the user. The code must be a TOTP code or a recovery code.
+func DeleteTOTP(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, ok := getRequestUser(resp, req)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		if err := edge.CheckTOTP(user, req.URL.Query().Get("code")); err != nil {
+			serveError(resp, err)
+			return
+		}
+	}
+
+	if err := edge.DisableTOTP(user.ID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] User %q disabled TOTP.", user.Username)
+}
+
+// DeleteUserTOTP implements DELETE /users/{id}/totp
+//
+// Resets the TOTP enrolment of a user (as admin), e.g. if the user lost the authenticator and recovery codes.
+func DeleteUserTOTP(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	userID := params.ByName("user_id")
+	if err := edge.DisableTOTP(userID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] TOTP of user %s reset by %s.", userID, getRequestActor(req))
+}
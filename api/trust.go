@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// trustedRole returns the role of requests from that IP address without token,
+// and the app (container name) if the request is from the wazigate docker network.
+// The role is "" if the IP address is not trusted.
+func trustedRole(ip net.IP) (role string, app string) {
+
+	policy, err := edge.GetTrustPolicy()
+	if err != nil {
+		log.Printf("[ERR  ] Trust policy: %v", err)
+		return "", ""
+	}
+
+	// loopback is not checked against the docker network
+	if !ip.IsLoopback() {
+		ok, err := IsDockerSubnet(ip)
+		if err != nil {
+			log.Printf("[ERR  ] Whitelist check for docker subnet failed for %q: %v", ip, err)
+		}
+		if ok {
+			app = dockerAppName(ip)
+			return appRole(policy, app), app
+		}
+	}
+	return policy.NetworkRole(ip), ""
+}
+
+// appOfContainer returns the app with an app key that runs that container, or "".
+var appOfContainer = edge.AppOfContainer
+
+// appRole returns the role of requests without token from that container.
+// Containers of apps with declared permissions must use their app key, unless the policy trusts them explicitly.
+// All other containers, also the ones with an unknown name, get the docker role of the policy.
+func appRole(policy *edge.TrustPolicy, container string) string {
+	if _, explicit := policy.Apps[container]; !explicit && container != "" && appOfContainer(container) != "" {
+		return ""
+	}
+	return policy.AppRole(container)
+}
+
+// requestIP returns the IP address of the request, or nil.
+func requestIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// dockerApps maps the IP addresses of containers in the wazigate network to their names.
+var dockerApps map[string]string
+var dockerAppsUpdated time.Time
+var dockerAppsMutex sync.Mutex
+
+// dockerAppsRefresh is the minimum time between two "docker network inspect" calls
+// for IP addresses that are not known yet.
+const dockerAppsRefresh = 10 * time.Second
+
+// dockerAppName returns the name of the container with that IP address in the wazigate network.
+func dockerAppName(ip net.IP) string {
+
+	dockerAppsMutex.Lock()
+	defer dockerAppsMutex.Unlock()
+
+	addr := ip.String()
+	if name, ok := dockerApps[addr]; ok {
+		return name
+	}
+	if time.Since(dockerAppsUpdated) < dockerAppsRefresh {
+		return ""
+	}
+	dockerAppsUpdated = time.Now()
+
+	dockerJSONRaw, err := tools.SockGetRequest("networks/wazigate")
+	if err != nil {
+		log.Printf("[ERR  ] Can not \"docker network inspect wazigate\": %v", err.Error())
+		return ""
+	}
+
+	var dockerNetwork struct {
+		Containers map[string]struct {
+			Name        string `json:"Name"`
+			IPv4Address string `json:"IPv4Address"`
+			IPv6Address string `json:"IPv6Address"`
+		} `json:"Containers"`
+	}
+	if err := json.Unmarshal(dockerJSONRaw, &dockerNetwork); err != nil {
+		log.Printf("[ERR  ] Can not unmarshal \"docker network inspect\" response: %s", err.Error())
+		return ""
+	}
+
+	dockerApps = make(map[string]string, len(dockerNetwork.Containers))
+	for _, container := range dockerNetwork.Containers {
+		for _, cidr := range []string{container.IPv4Address, container.IPv6Address} {
+			if i := strings.IndexByte(cidr, '/'); i != -1 {
+				dockerApps[cidr[:i]] = container.Name
+			}
+		}
+	}
+	return dockerApps[addr]
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// GetTrustPolicy implements GET /sys/trust
+func GetTrustPolicy(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	policy, err := edge.GetTrustPolicy()
+	if err != nil {
+		serveError(resp, err)
+		return
+	}
+	tools.SendJSON(resp, policy)
+}
+
+// PostTrustPolicy implements POST /sys/trust
+//
+// Sets the networks and apps that may use the API without token:
+//
+//	{
+//	  "networks": [{"name": "loopback", "cidr": "127.0.0.0/8", "role": "admin"}],
+//	  "dockerRole": "viewer", // "" to require tokens for apps
+//	  "apps": {"waziup.wazigate-lora": "device-writer"}
+//	}
+func PostTrustPolicy(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var policy edge.TrustPolicy
+	if err := unmarshalRequestBody(req, &policy); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := edge.SetTrustPolicy(&policy); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] Trust policy changed by %s.", getRequestActor(req))
+}
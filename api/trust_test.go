@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func TestAppRole(t *testing.T) {
+
+	defer func() {
+		appOfContainer = edge.AppOfContainer
+	}()
+	appOfContainer = func(container string) string {
+		if container == "waziup.app" || container == "app-db" {
+			return "waziup.app"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		dockerRole string
+		apps       map[string]string
+		container  string
+		role       string
+	}{
+		// apps with a key must use it, also from containers that are not named like the app
+		{edge.RoleAdmin, nil, "waziup.app", ""},
+		{edge.RoleAdmin, nil, "app-db", ""},
+		{edge.RoleAdmin, map[string]string{"app-db": edge.RoleViewer}, "app-db", edge.RoleViewer},
+		// containers without app key and unknown containers fall back to the docker role
+		{edge.RoleAdmin, nil, "other", edge.RoleAdmin},
+		{edge.RoleAdmin, nil, "", edge.RoleAdmin},
+		{edge.RoleViewer, nil, "other", edge.RoleViewer},
+		{"", nil, "other", ""},
+		{"", map[string]string{"other": edge.RoleViewer}, "other", edge.RoleViewer},
+	}
+
+	for _, test := range tests {
+		policy := &edge.TrustPolicy{DockerRole: test.dockerRole, Apps: test.apps}
+		if role := appRole(policy, test.container); role != test.role {
+			t.Errorf("%q (docker role %q, apps %v): got role %q, want %q", test.container, test.dockerRole, test.apps, role, test.role)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/Waziup/wazigate-edge/tools"
+	"github.com/globalsign/mgo"
+	routing "github.com/julienschmidt/httprouter"
+)
+
+// GetUsers implements GET /users
+func GetUsers(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	users := edge.GetUsers()
+	defer users.Close()
+	encoder := json.NewEncoder(resp)
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write([]byte{'['})
+	user, _ := users.Next()
+	for user != nil {
+		user.Password = ""
+		user.Role = user.EffectiveRole()
+		encoder.Encode(user)
+		user, _ = users.Next()
+		if user != nil {
+			resp.Write([]byte{','})
+		}
+	}
+	resp.Write([]byte{']'})
+}
+
+// PostUsers implements POST /users
+//
+// Creates a new user with name, username, password and role. The response is the user ID.
+func PostUsers(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var user edge.User
+	if err := unmarshalRequestBody(req, &user); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := edge.CheckPasswordPolicy(user.Username, user.Password); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	if err := edge.AddUser(&user); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] User %q (%s) created by %s.", user.Username, user.Role, getRequestActor(req))
+
+	// the request body is published with MQTT, so remove the password
+	user.Password = ""
+	tools.SetRequestBody(req, &user)
+	tools.SendJSON(resp, user.ID)
+}
+
+// GetUser implements GET /users/{id}
+func GetUser(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	user, err := edge.GetUser(params.ByName("user_id"))
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			http.Error(resp, "user not found", http.StatusNotFound)
+			return
+		}
+		serveError(resp, err)
+		return
+	}
+
+	user.Password = ""
+	user.Role = user.EffectiveRole()
+	tools.SendJSON(resp, user)
+}
+
+// PostUser implements POST /users/{id}
+//
+// Changes the name, role or password ("newPassword") of a user.
+func PostUser(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	var user edge.User
+	if err := unmarshalRequestBody(req, &user); err != nil {
+		http.Error(resp, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := params.ByName("user_id")
+	if err := edge.SetUser(userID, &user); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] User %s changed by %s.", userID, getRequestActor(req))
+
+	user.Password = ""
+	user.NewPassword = ""
+	tools.SetRequestBody(req, &user)
+}
+
+// DeleteUser implements DELETE /users/{id}
+func DeleteUser(resp http.ResponseWriter, req *http.Request, params routing.Params) {
+
+	userID := params.ByName("user_id")
+	if err := edge.DeleteUser(userID); err != nil {
+		serveError(resp, err)
+		return
+	}
+
+	log.Printf("[INFO ] User %s deleted by %s.", userID, getRequestActor(req))
+}
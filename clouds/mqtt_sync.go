@@ -15,15 +15,22 @@ import (
 	"github.com/Waziup/wazigate-edge/mqtt"
 )
 
-type namedSender struct {
-	name string
+// cloudSender is the sender of messages from the cloud (downstream).
+type cloudSender struct {
+	cloudID string
 }
 
-func (s namedSender) ID() string {
-	return s.name
+func (s cloudSender) ID() string {
+	return "downstream-" + s.cloudID
 }
 
-var cloudSender = namedSender{"downstream"}
+// SenderCloudID returns the cloud ID if the sender is a cloud, or "".
+func SenderCloudID(sender mqtt.Sender) string {
+	if s, ok := sender.(cloudSender); ok {
+		return s.cloudID
+	}
+	return ""
+}
 
 // IncludeDevice tells the cloud to sync with that device,
 // especially to monitor that device at the remote cloud for actuation data.
@@ -130,7 +137,7 @@ func (cloud *Cloud) mqttPersistentSync() {
 
 			if downstream != nil {
 				log.Printf("[UP   ] Received: %s [%d]", msg.Topic, len(msg.Data))
-				downstream.Publish(cloudSender, msg)
+				downstream.Publish(cloudSender{cloud.ID}, msg)
 			}
 		}
 
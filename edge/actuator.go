@@ -89,7 +89,6 @@ func GetActuator(deviceID string, actuatorID string) (*Actuator, error) {
 
 // PostActuator creates a new actuator for this device.
 func PostActuator(deviceID string, actuator *Actuator) error {
-
 	if actuator.ID == "" {
 		actuator.ID = bson.NewObjectId().Hex()
 	}
@@ -246,6 +245,7 @@ func DeleteActuator(deviceID string, actuatorID string) (int, error) {
 		"deviceId":   deviceID,
 		"actuatorId": actuatorID,
 	})
+	deleteCommands(deviceID, actuatorID)
 
 	if err1 != nil || err2 != nil {
 		err := err1
@@ -334,7 +334,8 @@ func PostActuatorValue(deviceID string, actuatorID string, val Value) (Meta, err
 		"actuators.id": actuatorID,
 	}).Select(
 		bson.M{
-			"actuators.id": actuatorID,
+			"actuators.id":   1,
+			"actuators.meta": 1,
 		},
 	).Apply(mgo.Change{
 		Update: bson.M{
@@ -362,12 +363,22 @@ func PostActuatorValue(deviceID string, actuatorID string, val Value) (Meta, err
 		return nil, CodeError{500, "database error: " + err.Error()}
 	}
 
-	return device.Actuators[0].Meta, nil
+	for _, actuator := range device.Actuators {
+		if actuator.ID == actuatorID {
+			return actuator.Meta, nil
+		}
+	}
+
+	return nil, nil
 }
 
 // PostActuatorValues can be used to post multiple data point for this actuator.
 func PostActuatorValues(deviceID string, actuatorID string, vals []Value) (Meta, error) {
 
+	if len(vals) == 0 {
+		return nil, errNoValues
+	}
+
 	values := make([]aValue, len(vals))
 	interf := make([]interface{}, len(vals))
 
@@ -389,7 +400,8 @@ func PostActuatorValues(deviceID string, actuatorID string, vals []Value) (Meta,
 		"actuators.id": actuatorID,
 	}).Select(
 		bson.M{
-			"actuators.id": actuatorID,
+			"actuators.id":   1,
+			"actuators.meta": 1,
 		},
 	).Apply(mgo.Change{
 		Update: bson.M{
@@ -416,5 +428,11 @@ func PostActuatorValues(deviceID string, actuatorID string, vals []Value) (Meta,
 		return nil, CodeError{500, "database error: " + err.Error()}
 	}
 
-	return device.Actuators[0].Meta, nil
+	for _, actuator := range device.Actuators {
+		if actuator.ID == actuatorID {
+			return actuator.Meta, nil
+		}
+	}
+
+	return nil, nil
 }
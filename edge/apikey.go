@@ -0,0 +1,315 @@
+package edge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// APIKeyPrefix is the prefix of all API keys, so they can be told apart from JWT tokens.
+const APIKeyPrefix = "wzk_"
+
+// APIKey is a long-lived credential of a user for integrations and devices.
+// Only a hash of the key is stored, the key itself is returned once at creation.
+type APIKey struct {
+	ID     string `json:"id" bson:"_id"`
+	Name   string `json:"name" bson:"name"`
+	UserID string `json:"userId" bson:"userId"`
+	// Prefix are the first characters of the key, to recognize it.
+	Prefix string `json:"prefix" bson:"prefix"`
+	Hash   string `json:"-" bson:"hash"`
+	// Permissions limit the key to these permissions (like "devices:write") of the user.
+	// All permissions of the user if empty.
+	Permissions []string `json:"permissions" bson:"permissions"`
+	// Devices limit the key to these devices (/devices/{id}/...). All devices if empty.
+	Devices []string `json:"devices" bson:"devices"`
+	// App is set for the keys of installed apps, that have no user.
+	// Their permissions are declared by the app (package.json), empty means none.
+	App string `json:"app,omitempty" bson:"app,omitempty"`
+	// Topics (MQTT topic filters like "devices/+/sensors/#") are allowed in addition to the permissions.
+	// Only for app keys.
+	Topics []string `json:"topics,omitempty" bson:"topics,omitempty"`
+	// Containers are the docker container names of the app. Only for app keys.
+	// Requests from these containers must use the key, see AppOfContainer.
+	Containers []string   `json:"containers,omitempty" bson:"containers,omitempty"`
+	Created    time.Time  `json:"created" bson:"created"`
+	LastUsed   *time.Time `json:"lastUsed" bson:"lastUsed"`
+}
+
+var errAPIKeyNotFound = CodeError{404, "api key not found"}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// PostAPIKey creates a new API key for the user and returns the key.
+// The permissions of the key must be granted by the role of the user.
+func PostAPIKey(apiKey *APIKey) (string, error) {
+
+	apiKey.App = ""
+	apiKey.Topics = nil
+
+	user, err := GetUser(apiKey.UserID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return "", errUserNotFound
+		}
+		return "", CodeError{500, "database error: " + err.Error()}
+	}
+	for _, p := range apiKey.Permissions {
+		if !HasPermission(user.EffectiveRole(), p) {
+			return "", CodeError{403, "the user has no permission " + p}
+		}
+	}
+	if apiKey.Permissions == nil {
+		apiKey.Permissions = []string{}
+	}
+	if apiKey.Devices == nil {
+		apiKey.Devices = []string{}
+	}
+	return insertAPIKey(apiKey)
+}
+
+func insertAPIKey(apiKey *APIKey) (string, error) {
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", CodeError{500, "can not create key: " + err.Error()}
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey.ID = bson.NewObjectId().Hex()
+	apiKey.Prefix = key[:len(APIKeyPrefix)+6]
+	apiKey.Hash = hashAPIKey(key)
+	apiKey.Created = time.Now()
+	apiKey.LastUsed = nil
+
+	if err := dbAPIKeys.Insert(apiKey); err != nil {
+		return "", CodeError{500, "database error: " + err.Error()}
+	}
+	return key, nil
+}
+
+// PostAppKey creates a new key for the app with the permissions and topics declared by the app.
+// The containers are the docker container names of the app. Older keys of the app are revoked.
+func PostAppKey(appID string, permissions []string, topics []string, containers []string) (string, error) {
+
+	for _, p := range permissions {
+		if !strings.Contains(p, ":") {
+			return "", CodeError{400, "invalid permission " + p + ", must be {resource}:{action}"}
+		}
+	}
+	if err := DeleteAppKeys(appID); err != nil {
+		return "", err
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+	apiKey := &APIKey{
+		Name:        "app " + appID,
+		App:         appID,
+		Permissions: permissions,
+		Devices:     []string{},
+		Topics:      topics,
+		Containers:  containers,
+	}
+	defer resetAppContainers()
+	return insertAPIKey(apiKey)
+}
+
+// DeleteAppKeys revokes all keys of the app.
+func DeleteAppKeys(appID string) error {
+
+	defer resetAppContainers()
+	_, err := dbAPIKeys.RemoveAll(bson.M{"app": appID})
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// SetAppContainers changes the docker container names of the app key, e.g. after the app has been started.
+func SetAppContainers(appID string, containers []string) error {
+
+	defer resetAppContainers()
+	_, err := dbAPIKeys.UpdateAll(bson.M{"app": appID}, bson.M{
+		"$set": bson.M{"containers": containers},
+	})
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// appContainers maps container names to the apps with a key, nil if not loaded yet.
+var appContainers map[string]string
+var appContainersMutex sync.Mutex
+
+func resetAppContainers() {
+	appContainersMutex.Lock()
+	appContainers = nil
+	appContainersMutex.Unlock()
+}
+
+// containerApps maps the containers of the app keys (and the app ids) to the apps.
+func containerApps(keys []APIKey) map[string]string {
+	m := make(map[string]string)
+	for _, key := range keys {
+		m[key.App] = key.App
+		for _, container := range key.Containers {
+			m[container] = key.App
+		}
+	}
+	return m
+}
+
+// AppOfContainer returns the app with a key that runs that docker container, or "".
+// Containers are known by the names stored with the key, or by the app id.
+// Apps with a key must use it instead of being trusted by IP address.
+func AppOfContainer(container string) string {
+
+	appContainersMutex.Lock()
+	defer appContainersMutex.Unlock()
+
+	if appContainers == nil {
+		var keys []APIKey
+		err := dbAPIKeys.Find(bson.M{
+			"app": bson.M{"$exists": true, "$ne": ""},
+		}).Select(bson.M{"app": 1, "containers": 1}).All(&keys)
+		if err != nil {
+			log.Printf("[ERR  ] App keys: %v", err)
+			return ""
+		}
+		appContainers = containerApps(keys)
+	}
+	return appContainers[container]
+}
+
+// GetAPIKeys returns the API keys of that user, or of all users if userID is empty.
+func GetAPIKeys(userID string) ([]APIKey, error) {
+
+	var query bson.M
+	if userID != "" {
+		query = bson.M{"userId": userID}
+	}
+	keys := []APIKey{}
+	err := dbAPIKeys.Find(query).Sort("created").All(&keys)
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return keys, nil
+}
+
+// GetAPIKey returns the API key with that ID.
+func GetAPIKey(keyID string) (*APIKey, error) {
+
+	var apiKey APIKey
+	err := dbAPIKeys.FindId(keyID).One(&apiKey)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, errAPIKeyNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &apiKey, nil
+}
+
+// DeleteAPIKey revokes the API key.
+func DeleteAPIKey(keyID string) error {
+
+	err := dbAPIKeys.RemoveId(keyID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errAPIKeyNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// CheckAPIKey returns the API key if the key is valid.
+func CheckAPIKey(key string) (*APIKey, error) {
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, CodeError{401, "invalid api key"}
+	}
+	var apiKey APIKey
+	now := time.Now()
+	_, err := dbAPIKeys.Find(bson.M{
+		"hash": hashAPIKey(key),
+	}).Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"lastUsed": now,
+			},
+		},
+	}, &apiKey)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, CodeError{401, "invalid api key"}
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &apiKey, nil
+}
+
+// Allows checks if the key grants the permission (like "devices:write") for the user's role.
+// deviceID is the device of the request, or "" if the request is not for a single device.
+// topic is the path of the request (without leading '/') that is checked against the topics of app keys,
+// or "" if the topics do not apply: they allow reading, subscribing and MQTT publishes only.
+func (apiKey *APIKey) Allows(role string, permission string, deviceID string, topic string) bool {
+
+	if permission == "" {
+		return true
+	}
+	if topic != "" {
+		for _, filter := range apiKey.Topics {
+			if MatchTopic(filter, topic) {
+				return true
+			}
+		}
+	}
+	if apiKey.App != "" && len(apiKey.Permissions) == 0 {
+		return false
+	}
+	if !HasPermission(role, permission) {
+		return false
+	}
+	if len(apiKey.Permissions) != 0 && !MatchPermission(apiKey.Permissions, permission) {
+		return false
+	}
+	if len(apiKey.Devices) != 0 {
+		for _, d := range apiKey.Devices {
+			if d == deviceID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// MatchTopic checks if the topic matches the MQTT topic filter with the wildcards '+' and '#'.
+// The topic may be a filter too (of a subscription): it matches if the filter covers all its topics.
+func MatchTopic(filter string, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || t[i] == "#" || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
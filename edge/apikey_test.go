@@ -0,0 +1,104 @@
+package edge
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+
+	tests := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"devices/d1/sensors/s1/value", "devices/d1/sensors/s1/value", true},
+		{"devices/d1/sensors/s1/value", "devices/d1/sensors/s2/value", false},
+		{"devices/+/sensors/+/value", "devices/d1/sensors/s1/value", true},
+		{"devices/+/sensors/+/value", "devices/d1/sensors/s1", false},
+		{"devices/+", "devices/d1/sensors", false},
+		{"devices/#", "devices", true},
+		{"devices/#", "devices/d1/sensors/s1/value", true},
+		{"#", "sys/uptime", true},
+		{"devices/d1", "devices/d1/sensors", false},
+		{"devices/d1/sensors", "devices/d1", false},
+		// the topic can be the filter of a subscription
+		{"devices/#", "devices/+/sensors/#", true},
+		{"devices/+/sensors/#", "devices/+/sensors/s1/value", true},
+		{"devices/+/sensors/#", "devices/#", false},
+		{"devices/d1/#", "devices/+/sensors", false},
+		{"devices/+/value", "devices/#", false},
+		{"#", "#", true},
+	}
+
+	for _, test := range tests {
+		if m := MatchTopic(test.filter, test.topic); m != test.match {
+			t.Errorf("%s %s: got %v, want %v", test.filter, test.topic, m, test.match)
+		}
+	}
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+
+	userKey := &APIKey{}
+	limitedKey := &APIKey{
+		Permissions: []string{"devices:write"},
+		Devices:     []string{"d1"},
+	}
+	appKey := &APIKey{
+		App:         "waziup.app",
+		Permissions: []string{"devices:read"},
+		Topics:      []string{"clouds/#"},
+	}
+	topicsKey := &APIKey{
+		App:    "waziup.other",
+		Topics: []string{"devices/+/sensors/#"},
+	}
+
+	tests := []struct {
+		name       string
+		key        *APIKey
+		role       string
+		permission string
+		deviceID   string
+		topic      string
+		allows     bool
+	}{
+		{"no permission needed", topicsKey, RoleViewer, "", "", "", true},
+		{"user key", userKey, RoleOperator, "codecs:write", "", "", true},
+		{"user key, not the user's permission", userKey, RoleViewer, "codecs:write", "", "", false},
+		{"limited key", limitedKey, RoleAdmin, "devices:write", "d1", "", true},
+		{"limited key, other permission", limitedKey, RoleAdmin, "codecs:write", "", "", false},
+		{"limited key, other device", limitedKey, RoleAdmin, "devices:write", "d2", "", false},
+		{"limited key, no device", limitedKey, RoleAdmin, "devices:write", "", "", false},
+		{"limited key, not the user's permission", limitedKey, RoleViewer, "devices:write", "d1", "", false},
+		{"app key", appKey, RoleAdmin, "devices:read", "d1", "", true},
+		{"app key, other permission", appKey, RoleAdmin, "devices:write", "d1", "", false},
+		{"app key, topic", appKey, RoleAdmin, "clouds:write", "", "clouds/c1/paused", true},
+		{"app key, topics do not apply", appKey, RoleAdmin, "clouds:write", "", "", false},
+		{"app key, other topic", appKey, RoleAdmin, "users:read", "", "users/u1", false},
+		{"topics key", topicsKey, RoleAdmin, "devices:read", "d1", "devices/d1/sensors/s1/value", true},
+		{"topics key, no permissions", topicsKey, RoleAdmin, "devices:read", "d1", "devices/d1", false},
+	}
+
+	for _, test := range tests {
+		if a := test.key.Allows(test.role, test.permission, test.deviceID, test.topic); a != test.allows {
+			t.Errorf("%s: got %v, want %v", test.name, a, test.allows)
+		}
+	}
+}
+
+func TestContainerApps(t *testing.T) {
+
+	m := containerApps([]APIKey{
+		{App: "waziup.wazigate-lora", Containers: []string{"waziup.wazigate-lora", "chirpstack"}},
+		{App: "waziup.app2"},
+	})
+	for container, app := range map[string]string{
+		"waziup.wazigate-lora": "waziup.wazigate-lora",
+		"chirpstack":           "waziup.wazigate-lora",
+		"waziup.app2":          "waziup.app2",
+		"other":                "",
+	} {
+		if m[container] != app {
+			t.Errorf("%s: got app %q, want %q", container, m[container], app)
+		}
+	}
+}
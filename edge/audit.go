@@ -0,0 +1,185 @@
+package edge
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Audit actor types.
+const (
+	// ActorUser is a logged in user, the ID is the user ID.
+	ActorUser = "user"
+	// ActorAPIKey is an API key, the ID is the key ID.
+	ActorAPIKey = "apikey"
+	// ActorCloud is a cloud (downstream sync), the ID is the cloud ID.
+	ActorCloud = "cloud"
+	// ActorMQTT is a MQTT client without API key, the ID is the client ID.
+	ActorMQTT = "mqtt"
+	// ActorContainer is a docker container (app), the ID is its IP address.
+	ActorContainer = "container"
+	// ActorLocal is the gateway itself, the ID is the loopback address.
+	ActorLocal = "local"
+	// ActorIP is any other (not logged in) client, the ID is its IP address.
+	ActorIP = "ip"
+)
+
+// AuditActor is who did a change.
+type AuditActor struct {
+	Type string `json:"type" bson:"type"`
+	ID   string `json:"id" bson:"id"`
+	// Name is the name of the API key or app (container), if any.
+	Name string `json:"name,omitempty" bson:"name,omitempty"`
+	// UserID and Username are set for users and API keys.
+	UserID   string `json:"userId,omitempty" bson:"userId,omitempty"`
+	Username string `json:"username,omitempty" bson:"username,omitempty"`
+}
+
+// AuditEntry records a state-changing request.
+type AuditEntry struct {
+	ID    bson.ObjectId `json:"id" bson:"_id"`
+	Time  time.Time     `json:"time" bson:"time"`
+	Actor AuditActor    `json:"actor" bson:"actor"`
+	// Proto is "http", "https" or "mqtt".
+	Proto string `json:"proto" bson:"proto"`
+	// Method is POST, PUT, DELETE or PUBLISH.
+	Method string `json:"method" bson:"method"`
+	// Entity is the path of the request (the MQTT topic), like "devices/{id}/actuators/{id}/value".
+	Entity string `json:"entity" bson:"entity"`
+	Status int    `json:"status" bson:"status"`
+	// Change summarizes the change, usually the (shortened) request body without secrets.
+	Change string `json:"change" bson:"change"`
+}
+
+// PostAuditEntry appends the entry to the audit log.
+// The audit log is a capped collection: entries can not be changed or deleted,
+// the oldest entries are dropped when the log is full.
+func PostAuditEntry(entry *AuditEntry) error {
+
+	if entry.Time == noTime {
+		entry.Time = time.Now()
+	}
+	entry.ID = newID(entry.Time)
+
+	if err := dbAudit.Insert(entry); err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// AuditQuery filters the audit log.
+type AuditQuery struct {
+	Limit int64
+	From  time.Time
+	To    time.Time
+	// ActorType, ActorID and UserID filter by the actor.
+	ActorType string
+	ActorID   string
+	UserID    string
+	// Entity filters by the path prefix, like "devices/{id}".
+	Entity string
+	Method string
+}
+
+// Parse reads url.Values into the AuditQuery.
+func (query *AuditQuery) Parse(req *http.Request) string {
+	var param string
+	var err error
+
+	q := req.URL.Query()
+
+	if param = q.Get("from"); param != "" {
+		err = query.From.UnmarshalText([]byte(param))
+		if err != nil {
+			return "Query ?from=.. is mal formatted."
+		}
+	}
+
+	if param = q.Get("to"); param != "" {
+		err = query.To.UnmarshalText([]byte(param))
+		if err != nil {
+			return "Query ?to=.. is mal formatted."
+		}
+	}
+
+	if param = q.Get("limit"); param != "" {
+		query.Limit, err = strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return "Query ?limit=.. is mal formatted."
+		}
+	}
+
+	query.ActorType = q.Get("actor")
+	query.ActorID = q.Get("actorId")
+	query.UserID = q.Get("user")
+	query.Entity = q.Get("entity")
+	query.Method = q.Get("method")
+
+	return ""
+}
+
+// GetAudit returns the audit log entries matching the query, oldest first.
+func GetAudit(query *AuditQuery) *AuditIter {
+	m := bson.M{}
+	if query.From != noTime || query.To != noTime {
+		mid := bson.M{}
+		m["_id"] = mid
+		if query.From != noTime {
+			mid["$gte"] = bson.NewObjectIdWithTime(query.From)
+		}
+		if query.To != noTime {
+			mid["$lt"] = bson.NewObjectIdWithTime(query.To.Add(time.Second))
+		}
+	}
+	if query.ActorType != "" {
+		m["actor.type"] = query.ActorType
+	}
+	if query.ActorID != "" {
+		m["actor.id"] = query.ActorID
+	}
+	if query.UserID != "" {
+		m["actor.userId"] = query.UserID
+	}
+	if query.Entity != "" {
+		m["entity"] = bson.RegEx{Pattern: "^" + regexp.QuoteMeta(query.Entity)}
+	}
+	if query.Method != "" {
+		m["method"] = query.Method
+	}
+	q := dbAudit.Find(m).Sort("_id")
+	if query.Limit != 0 {
+		q.Limit(int(query.Limit))
+	}
+
+	return &AuditIter{
+		dbIter: q.Iter(),
+	}
+}
+
+// AuditIter iterates over audit log entries. Call .Next() to get the next entry.
+type AuditIter struct {
+	dbIter *mgo.Iter
+	entry  AuditEntry
+}
+
+// Next returns the next entry or io.EOF.
+func (iter *AuditIter) Next() (*AuditEntry, error) {
+	iter.entry = AuditEntry{}
+	if iter.dbIter.Next(&iter.entry) {
+		return &iter.entry, iter.dbIter.Err()
+	}
+	if err := iter.dbIter.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close closes the iterator.
+func (iter *AuditIter) Close() error {
+	return iter.dbIter.Close()
+}
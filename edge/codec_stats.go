@@ -0,0 +1,126 @@
+package edge
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CodecStatsSize is the number of durations and errors kept per codec.
+const CodecStatsSize = 100
+
+// CodecStats are the execution counters of one codec since the gateway started.
+type CodecStats struct {
+	Invocations int64 `json:"invocations"`
+	Failures    int64 `json:"failures"`
+	// P50 and P95 are the median and 95th percentile durations in milliseconds
+	// of the last CodecStatsSize calls.
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	// Errors are the last CodecStatsSize errors, oldest first.
+	Errors []CodecError `json:"errors"`
+}
+
+// CodecError is a failed codec call.
+type CodecError struct {
+	Time     time.Time `json:"time"`
+	DeviceID string    `json:"deviceId"`
+	// Direction is "uplink" (UnmarshalDevice) or "downlink" (MarshalDevice).
+	Direction string `json:"direction"`
+	// Version is the codec version, if the device is pinned to one.
+	Version int `json:"version,omitempty"`
+	// Payload is the hex encoded uplink payload.
+	Payload string `json:"payload,omitempty"`
+	Error   string `json:"error"`
+}
+
+type codecStats struct {
+	invocations int64
+	failures    int64
+	durations   []time.Duration // ring buffer
+	errors      []CodecError    // ring buffer
+	nDurations  int
+	nErrors     int
+}
+
+var codecsStats = map[string]*codecStats{}
+var codecsStatsMutex sync.Mutex
+
+// recordCodecCall adds one call to the stats of that codec.
+// Calls of a pinned version ("{id}@{version}") count for the codec id.
+func recordCodecCall(name string, deviceID string, direction string, payload []byte, duration time.Duration, err error) {
+	codecID, version := splitCodecVersion(name)
+
+	codecsStatsMutex.Lock()
+	defer codecsStatsMutex.Unlock()
+
+	stats := codecsStats[codecID]
+	if stats == nil {
+		stats = &codecStats{
+			durations: make([]time.Duration, CodecStatsSize),
+			errors:    make([]CodecError, CodecStatsSize),
+		}
+		codecsStats[codecID] = stats
+	}
+
+	stats.invocations++
+	stats.durations[stats.nDurations%CodecStatsSize] = duration
+	stats.nDurations++
+
+	if err != nil {
+		stats.failures++
+		stats.errors[stats.nErrors%CodecStatsSize] = CodecError{
+			Time:      time.Now(),
+			DeviceID:  deviceID,
+			Direction: direction,
+			Version:   version,
+			Payload:   hex.EncodeToString(payload),
+			Error:     err.Error(),
+		}
+		stats.nErrors++
+	}
+}
+
+// GetCodecStats returns the execution counters of that codec, including all its versions.
+func GetCodecStats(codecID string) *CodecStats {
+	codecID, _ = splitCodecVersion(codecID)
+
+	codecsStatsMutex.Lock()
+	defer codecsStatsMutex.Unlock()
+
+	result := &CodecStats{
+		Errors: []CodecError{},
+	}
+	stats := codecsStats[codecID]
+	if stats == nil {
+		return result
+	}
+	result.Invocations = stats.invocations
+	result.Failures = stats.failures
+
+	n := stats.nDurations
+	if n > CodecStatsSize {
+		n = CodecStatsSize
+	}
+	durations := make([]time.Duration, n)
+	copy(durations, stats.durations[:n])
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	if n != 0 {
+		result.P50 = durations[(n-1)*50/100].Seconds() * 1000
+		result.P95 = durations[(n-1)*95/100].Seconds() * 1000
+	}
+
+	start := 0
+	n = stats.nErrors
+	if n > CodecStatsSize {
+		start = n % CodecStatsSize
+		n = CodecStatsSize
+	}
+	for i := 0; i < n; i++ {
+		result.Errors = append(result.Errors, stats.errors[(start+i)%CodecStatsSize])
+	}
+	return result
+}
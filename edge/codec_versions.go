@@ -0,0 +1,153 @@
+package edge
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// CodecVersion is an immutable copy of a codec, created with every save.
+// Devices can pin a version with the meta codec "{codecID}@{version}".
+type CodecVersion struct {
+	ID      string      `json:"-" bson:"_id"`
+	CodecID string      `json:"codecId" bson:"codecId"`
+	Version int         `json:"version" bson:"version"`
+	Time    time.Time   `json:"time" bson:"time"`
+	Author  string      `json:"author" bson:"author"`
+	Codec   ScriptCodec `json:"codec" bson:"codec"`
+}
+
+var errVersionNotFound = CodeError{404, "codec version not found"}
+
+// codecVersionsMutex makes sure that version numbers are unique.
+var codecVersionsMutex sync.Mutex
+
+// nextCodecVersion assigns the next version number to the codec.
+// The codecVersionsMutex must be held until the version is stored with postCodecVersion.
+func nextCodecVersion(codec *ScriptCodec) error {
+
+	var last CodecVersion
+	err := dbCodecVersions.Find(bson.M{
+		"codecId": codec.ID,
+	}).Sort("-version").One(&last)
+	if err != nil && err != mgo.ErrNotFound {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	if err == mgo.ErrNotFound {
+		// codecs stored before there were versions keep their content as first version
+		var stored ScriptCodec
+		err := dbCodecs.FindId(codec.ID).One(&stored)
+		if err != nil && err != mgo.ErrNotFound {
+			return CodeError{500, "database error: " + err.Error()}
+		}
+		if err == nil {
+			stored.Version = 1
+			if stored.Modified.IsZero() {
+				stored.Modified = time.Now()
+			}
+			if err := postCodecVersion(&stored); err != nil {
+				return err
+			}
+			last.Version = stored.Version
+		}
+	}
+
+	codec.Version = last.Version + 1
+	codec.Modified = time.Now()
+	codec.Internal = false
+	return nil
+}
+
+// postCodecVersion stores a copy of the codec, after the codec itself has been saved.
+func postCodecVersion(codec *ScriptCodec) error {
+
+	err := dbCodecVersions.Insert(&CodecVersion{
+		ID:      codec.ID + "@" + strconv.Itoa(codec.Version),
+		CodecID: codec.ID,
+		Version: codec.Version,
+		Time:    codec.Modified,
+		Author:  codec.Author,
+		Codec:   *codec,
+	})
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// GetCodecVersions returns all versions of that codec, newest first.
+func GetCodecVersions(codecID string) ([]CodecVersion, error) {
+
+	versions := []CodecVersion{}
+	err := dbCodecVersions.Find(bson.M{
+		"codecId": codecID,
+	}).Sort("-version").All(&versions)
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return versions, nil
+}
+
+// GetCodecVersion returns that version of the codec.
+func GetCodecVersion(codecID string, version int) (*ScriptCodec, error) {
+
+	var v CodecVersion
+	err := dbCodecVersions.FindId(codecID + "@" + strconv.Itoa(version)).One(&v)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, errVersionNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &v.Codec, nil
+}
+
+// RollbackCodec makes an old version the current version of the codec.
+// This creates a new version with the content of the old one.
+func RollbackCodec(codecID string, version int, author string) (*ScriptCodec, error) {
+
+	codec, err := GetCodecVersion(codecID, version)
+	if err != nil {
+		return nil, err
+	}
+	codec.Author = author
+	if err := PostCodec(codec); err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+// countPinnedDevices returns the number of devices that use a version of that codec,
+// with the meta codec or with a codec route.
+func countPinnedDevices(codecID string) (int, error) {
+	pinned := bson.M{"$regex": "^" + regexp.QuoteMeta(codecID) + "@[0-9]+$"}
+	n, err := dbDevices.Find(bson.M{
+		"$or": []bson.M{
+			{"meta.codec": pinned},
+			{"meta.codecs.codec": pinned},
+		},
+	}).Count()
+	if err != nil {
+		return 0, CodeError{500, "database error: " + err.Error()}
+	}
+	return n, nil
+}
+
+// splitCodecVersion splits a pinned codec like "{codecID}@{version}".
+// The version is 0 if the codec is not pinned.
+func splitCodecVersion(name string) (string, int) {
+	i := strings.LastIndexByte(name, '@')
+	if i == -1 {
+		return name, 0
+	}
+	version, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return name, 0
+	}
+	return name[:i], version
+}
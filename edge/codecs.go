@@ -1,22 +1,25 @@
 package edge
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 )
 
 type Codec interface {
-	UnmarshalDevice(deviceID string, headers http.Header, r io.Reader) error
-	MarshalDevice(deviceID string, headers http.Header, w io.Writer) error
+	UnmarshalDevice(store DeviceStore, deviceID string, headers http.Header, r io.Reader) error
+	MarshalDevice(store DeviceStore, deviceID string, headers http.Header, w io.Writer) error
 	CodecName() string
 }
 
 type ScriptExecutor interface {
-	UnmarshalDevice(script *ScriptCodec, deviceID string, headers http.Header, r io.Reader) error
-	MarshalDevice(script *ScriptCodec, deviceID string, headers http.Header, w io.Writer) error
+	UnmarshalDevice(script *ScriptCodec, store DeviceStore, deviceID string, headers http.Header, r io.Reader) error
+	MarshalDevice(script *ScriptCodec, store DeviceStore, deviceID string, headers http.Header, w io.Writer) error
 	ExecutorName() string
 }
 
@@ -30,26 +33,36 @@ type ScriptCodec struct {
 	ServeMime string `json:"serveMime" bson:"serveMime"`
 	Mime      string `json:"mime" bson:"mime"`
 	Script    string `json:"script" bson:"script"`
+	// Timeout is the max. execution time of one call, like "500ms" or "2s".
+	// The executor default is used if empty.
+	Timeout string `json:"timeout,omitempty" bson:"timeout,omitempty"`
+	// MemoryLimit is the max. memory in bytes that one call may allocate.
+	// The executor default is used if 0.
+	MemoryLimit uint64 `json:"memoryLimit,omitempty" bson:"memoryLimit,omitempty"`
+	// Version is incremented with every save, see CodecVersion.
+	Version  int       `json:"version" bson:"version"`
+	Author   string    `json:"author" bson:"author"`
+	Modified time.Time `json:"modified" bson:"modified"`
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
 var errNoExecutor = NewError(500, "the codec uses a mime that is unknown to the system")
 
-func (script *ScriptCodec) UnmarshalDevice(deviceID string, headers http.Header, r io.Reader) error {
+func (script *ScriptCodec) UnmarshalDevice(store DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
 	e := ScriptExecutors[script.Mime]
 	if e == nil {
 		return errNoExecutor
 	}
-	return e.UnmarshalDevice(script, deviceID, headers, r)
+	return e.UnmarshalDevice(script, store, deviceID, headers, r)
 }
 
-func (script *ScriptCodec) MarshalDevice(deviceID string, headers http.Header, w io.Writer) error {
+func (script *ScriptCodec) MarshalDevice(store DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
 	e := ScriptExecutors[script.Mime]
 	if e == nil {
 		return errNoExecutor
 	}
-	return e.MarshalDevice(script, deviceID, headers, w)
+	return e.MarshalDevice(script, store, deviceID, headers, w)
 }
 
 func (codec ScriptCodec) CodecName() string {
@@ -59,28 +72,86 @@ func (codec ScriptCodec) CodecName() string {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// PostCodec creates or changes a codec. Each call stores a new immutable version of the codec.
+// Set codec.Author before calling this.
 func PostCodec(codec *ScriptCodec) error {
 	if codec.ID == "" {
 		codec.ID = newID(time.Now()).Hex()
 	}
+	if strings.ContainsAny(codec.ID, "@/") {
+		return CodeError{400, "the codec id must not contain '@' or '/'"}
+	}
+	if codec.Timeout != "" {
+		if _, err := time.ParseDuration(codec.Timeout); err != nil {
+			return CodeError{400, "invalid timeout: " + err.Error()}
+		}
+	}
 	_, ok := ScriptExecutors[codec.Mime]
 	if !ok {
 		return errNoExecutor
 	}
+
+	codecVersionsMutex.Lock()
+	defer codecVersionsMutex.Unlock()
+
+	if err := nextCodecVersion(codec); err != nil {
+		return err
+	}
 	_, err := dbCodecs.UpsertId(codec.ID, codec)
 	if err != nil {
 		return CodeError{500, "database error: " + err.Error()}
 	}
-	return nil
+	return postCodecVersion(codec)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+var errCodecNotFound = CodeError{404, "codec not found"}
+
+// GetCodec returns the internal or script codec with that id.
+// Use "{id}@{version}" to get a specific version of a script codec.
+func GetCodec(id string) (*ScriptCodec, error) {
+	if codecID, version := splitCodecVersion(id); version != 0 {
+		return GetCodecVersion(codecID, version)
+	}
+	if codec, ok := Codecs[id]; ok {
+		return &ScriptCodec{
+			ID:        id,
+			ServeMime: id,
+			Name:      codec.CodecName(),
+			Mime:      "application/octet-stream",
+			Script:    "<internal>",
+			Internal:  true,
+		}, nil
+	}
+	var script ScriptCodec
+	err := dbCodecs.FindId(id).One(&script)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, errCodecNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &script, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// DeleteCodec removes the codec and its versions.
+// This fails while devices use a pinned version of the codec.
 func DeleteCodec(id string) error {
-	err := dbCodecs.RemoveId(id)
+	n, err := countPinnedDevices(id)
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return CodeError{409, fmt.Sprintf("%d devices use a pinned version of this codec", n)}
+	}
+	err = dbCodecs.RemoveId(id)
 	if err != nil {
 		return CodeError{500, "database error: " + err.Error()}
 	}
+	dbCodecVersions.RemoveAll(bson.M{"codecId": id})
 	return nil
 }
 
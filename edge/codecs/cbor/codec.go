@@ -0,0 +1,17 @@
+package codec
+
+import (
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func init() {
+	edge.Codecs["application/cbor"] = CBORCodec{}
+}
+
+func (CBORCodec) CodecName() string {
+	return "CBOR (Concise Binary Object Representation)"
+}
+
+// CBORCodec reads and writes a CBOR map with one entry per sensor (uplink) or actuator (downlink).
+// Keys can be strings or integers.
+type CBORCodec struct{}
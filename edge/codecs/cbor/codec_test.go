@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func TestUnmarshalDevice(t *testing.T) {
+
+	// {"temp": 21.5, 1: 3, "gps": {"lat": 1}} with a half-precision float
+	data, _ := hex.DecodeString("a36474656d70f94d60010363677073a1636c617401")
+	device := &edge.Device{
+		Sensors: []*edge.Sensor{{ID: "counter", Meta: edge.Meta{"cborKey": 1.0}}},
+	}
+	result := edge.TestCodec(CBORCodec{}, device, nil, data)
+
+	if len(result.Errors) != 0 {
+		t.Fatalf("errors: %v", result.Errors)
+	}
+	sensors := map[string]*edge.Sensor{}
+	for _, sensor := range result.Sensors {
+		sensors[sensor.Name] = sensor
+	}
+	if len(sensors) != 3 {
+		t.Fatalf("got %d sensors, want 3", len(result.Sensors))
+	}
+
+	if s := result.Sensors[0]; s.ID != "counter" || s.Value != uint64(3) {
+		t.Errorf("counter is %+v, want 3", s)
+	}
+	temp := sensors["temp"]
+	if temp == nil || temp.Value != 21.5 || temp.Meta["quantity"] != "Temperature" || temp.Meta["cborKey"] != "temp" {
+		t.Errorf("temp is %+v, want a temperature of 21.5", temp)
+	}
+	gps := sensors["gps"]
+	if gps == nil || !reflect.DeepEqual(gps.Value, map[string]interface{}{"lat": uint64(1)}) {
+		t.Errorf("gps is %+v, want {lat: 1}", gps)
+	}
+}
+
+func TestUnmarshalDeviceInvalid(t *testing.T) {
+
+	for _, payload := range []string{
+		"",     // empty
+		"a2",   // map without entries
+		"8101", // array, not a map
+	} {
+		data, _ := hex.DecodeString(payload)
+		result := edge.TestCodec(CBORCodec{}, nil, nil, data)
+		if len(result.Errors) == 0 {
+			t.Errorf("%q: no error", payload)
+		}
+	}
+}
+
+// deviceStore serves one device for MarshalDevice.
+type deviceStore struct {
+	edge.DeviceStore
+	device *edge.Device
+}
+
+func (s deviceStore) GetDevice(deviceID string) (*edge.Device, error) {
+	return s.device, nil
+}
+
+func TestMarshalDevice(t *testing.T) {
+
+	store := deviceStore{device: &edge.Device{
+		Actuators: []*edge.Actuator{
+			{ID: "relay", Value: true},
+			{ID: "fan", Value: 2.0, Meta: edge.Meta{"cborKey": 7.0}},
+			{ID: "led", Meta: edge.Meta{"cborKey": "l"}},
+		},
+	}}
+	var buf bytes.Buffer
+	if err := (CBORCodec{}).MarshalDevice(store, "", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// {7: 2.0, "relay": true} in deterministic (core) encoding with the shortest float,
+	// without the actuator that has no value
+	if h := hex.EncodeToString(buf.Bytes()); h != "a207f940006572656c6179f5" {
+		t.Errorf("got %s", h)
+	}
+}
@@ -0,0 +1,107 @@
+package codec
+
+type def struct {
+	Kind     string
+	Quantity string
+	Unit     string
+}
+
+var temperature = def{
+	Kind:     "Thermometer",
+	Quantity: "Temperature",
+	Unit:     "DegreeCelsius",
+}
+
+var humidity = def{
+	Kind:     "HumiditySensor",
+	Quantity: "RelativeHumidity",
+	Unit:     "Percent",
+}
+
+var pressure = def{
+	Kind:     "PressureSensor",
+	Quantity: "AtmosphericPressure",
+	Unit:     "Hectopascal",
+}
+
+var voltage = def{
+	Kind:     "VoltageSensor",
+	Quantity: "Voltage",
+	Unit:     "Volt",
+}
+
+var luminosity = def{
+	Kind:     "LightSensor",
+	Quantity: "Illuminance",
+	Unit:     "Lux",
+}
+
+var concentration = def{
+	Kind:     "GaseousPollutantSensor",
+	Quantity: "ChemicalAgentConcentration",
+	Unit:     "PartsPerMillion",
+}
+
+var position = def{
+	Kind:     "GPSSensor",
+	Quantity: "Position",
+	Unit:     "LatLong",
+}
+
+// keyMapping maps well-known (lower case) keys to the ontology.
+var keyMapping = map[string]def{
+	"temperature": temperature,
+	"temp":        temperature,
+	"humidity":    humidity,
+	"hum":         humidity,
+	"rh":          humidity,
+	"pressure":    pressure,
+	"voltage":     voltage,
+	"battery":     voltage,
+	"vbat":        voltage,
+	"current": {
+		Kind:     "ElectricalSensor",
+		Quantity: "ElectricCurrent",
+		Unit:     "Ampere",
+	},
+	"power": {
+		Kind:     "ElectricalSensor",
+		Quantity: "ActivePower",
+		Unit:     "Watt",
+	},
+	"energy": {
+		Kind:     "EnergyMeter",
+		Quantity: "Energy",
+		Unit:     "KiloWattHour",
+	},
+	"luminosity": luminosity,
+	"light":      luminosity,
+	"lux":        luminosity,
+	"co2":        concentration,
+	"distance": {
+		Kind:     "DistanceSensor",
+		Quantity: "Distance",
+		Unit:     "Metre",
+	},
+	"altitude": {
+		Quantity: "Altitude",
+		Unit:     "Metre",
+	},
+	"frequency": {
+		Kind:     "FrequencySensor",
+		Quantity: "Frequency",
+		Unit:     "Hertz",
+	},
+	"presence": {
+		Kind:     "HumanPresenceDetector",
+		Quantity: "Presence",
+	},
+	"direction": {
+		Kind:     "WindDirectionSensor",
+		Quantity: "WindDirection",
+		Unit:     "DegreeAngle",
+	},
+	"gps":      position,
+	"position": position,
+	"location": position,
+}
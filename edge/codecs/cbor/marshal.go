@@ -0,0 +1,40 @@
+package codec
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// MarshalDevice writes the actuator values as CBOR map.
+// The keys are the meta 'cborKey' of the actuators, or their IDs.
+func (CBORCodec) MarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	data := make(map[interface{}]interface{}, len(device.Actuators))
+	for _, actuator := range device.Actuators {
+		if actuator.Value == nil {
+			continue
+		}
+		var key interface{} = cborKey(actuator.ID, actuator.Meta)
+		if k, ok := actuator.Meta["cborKey"]; ok {
+			if _, ok := k.(string); !ok {
+				key, _ = strconv.ParseInt(key.(string), 10, 64)
+			}
+		}
+		data[key] = actuator.Value
+	}
+
+	encoder, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return err
+	}
+	return encoder.NewEncoder(w).Encode(data)
+}
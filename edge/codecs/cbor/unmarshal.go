@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/fxamacker/cbor/v2"
+)
+
+func (CBORCodec) UnmarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	var data map[interface{}]interface{}
+	if err := cbor.NewDecoder(r).Decode(&data); err != nil {
+		return edge.NewErrorf(400, "Err Codec CBOR: %v", err)
+	}
+
+	t := time.Now()
+
+	for key, value := range data {
+		if err := createSensor(store, device, key, jsonCompatible(value), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createSensor(store edge.DeviceStore, device *edge.Device, key interface{}, value interface{}, t time.Time) error {
+	k := fmt.Sprint(key)
+	for _, actuator := range device.Actuators {
+		if cborKey(actuator.ID, actuator.Meta) == k {
+			return nil
+		}
+	}
+	for _, sensor := range device.Sensors {
+		if cborKey(sensor.ID, sensor.Meta) == k {
+			v := edge.NewValue(value, t)
+			if _, err := store.PostSensorValue(device.ID, sensor.ID, v); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+	d := keyMapping[strings.ToLower(k)]
+	if _, ok := key.(string); !ok {
+		// integer keys are stored as numbers, so that downlinks use the same key type
+		key = toInt(key)
+	}
+	return store.PostSensor(device.ID, &edge.Sensor{
+		Name:  k,
+		Value: value,
+		Time:  &t,
+		Meta: edge.Meta{
+			"kind":      d.Kind,
+			"quantity":  d.Quantity,
+			"unit":      d.Unit,
+			"cborKey":   key,
+			"createdBy": "codec:cbor",
+		},
+	})
+}
+
+// cborKey returns the meta 'cborKey' or the ID of the sensor or actuator.
+func cborKey(id string, m edge.Meta) string {
+	if key, ok := m["cborKey"]; ok {
+		if f, ok := key.(float64); ok {
+			return fmt.Sprint(int64(f))
+		}
+		return fmt.Sprint(key)
+	}
+	return id
+}
+
+func toInt(key interface{}) interface{} {
+	switch k := key.(type) {
+	case uint64:
+		return int64(k)
+	case int64:
+		return k
+	}
+	return fmt.Sprint(key)
+}
+
+// jsonCompatible converts CBOR maps to maps with string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, value := range t {
+			l[i] = jsonCompatible(value)
+		}
+		return l
+	}
+	return v
+}
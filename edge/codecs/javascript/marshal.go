@@ -1,15 +1,117 @@
 package executor
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/dop251/goja"
 )
 
-var errNotImplemented = edge.NewError(500, "JavaScript Encoder is not implemented yet")
+// DefaultFPort is the LoRaWAN fPort of downlinks if neither the request nor the script sets one.
+const DefaultFPort = 1
 
-func (JavaScriptExecutor) MarshalDevice(script *edge.ScriptCodec, deviceID string, headers http.Header, w io.Writer) error {
+type EncoderOutput struct {
+	Bytes    []int    `json:"bytes"`
+	FPort    int      `json:"fPort"`
+	Warnings []string `json:"warnings"`
+	Errors   []string `json:"errors"`
+}
+
+// MarshalDevice creates a downlink with the actuator values of that device.
+// The request may ask for an fPort with the 'X-LoRaWAN-FPort' header.
+// The fPort of the downlink is set as 'X-LoRaWAN-FPort' header of w, if w has headers (like edge.DownlinkWriter).
+func (JavaScriptExecutor) MarshalDevice(script *edge.ScriptCodec, store edge.DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return edge.NewError(404, "no device with that id")
+	}
+
+	data := make(map[string]interface{}, len(device.Actuators))
+	for _, actuator := range device.Actuators {
+		if actuator.Value != nil {
+			data[actuator.ID] = actuator.Value
+		}
+	}
+
+	port, err := strconv.Atoi(headers.Get("X-LoRaWAN-FPort"))
+	if err != nil {
+		port = DefaultFPort
+	}
+
+	output, _, err := Encode(script, data, port)
+	if err != nil {
+		return err
+	}
+	if len(output.Errors) != 0 {
+		return edge.NewErrorf(500, "Err executing script: %s", strings.Join(output.Errors, "\n"))
+	}
+
+	payload := make([]byte, len(output.Bytes))
+	for i, b := range output.Bytes {
+		if b < 0 || b > 255 {
+			return edge.NewErrorf(500, "Err executing script: byte %d is out of range: %d", i, b)
+		}
+		payload[i] = byte(b)
+	}
+	if hw, ok := w.(interface{ Header() http.Header }); ok {
+		hw.Header().Set("X-LoRaWAN-FPort", strconv.Itoa(output.FPort))
+	}
+	_, err = w.Write(payload)
+	return err
+}
+
+// Encode runs the encoder of the script with that data (actuator values by actuator ID).
+// The script can use the TTN conventions `Encoder(object, fPort)` or `encodeDownlink({data, fPort})`.
+// This also returns everything logged by the script.
+func Encode(script *edge.ScriptCodec, data map[string]interface{}, fPort int) (*EncoderOutput, []string, error) {
+
+	v, err := newVM(script)
+	if err != nil {
+		return nil, nil, err
+	}
+	object := v.ToValue(data)
+	port := v.ToValue(fPort)
+
+	output := EncoderOutput{
+		FPort: fPort,
+	}
+
+	if encoder := v.function("Encoder"); encoder != nil {
+		result, err := v.call(func() (goja.Value, error) {
+			return encoder(goja.Undefined(), object, port)
+		})
+		if err != nil {
+			return nil, v.logs, v.error(err)
+		}
+		if err := v.export(result, &output.Bytes); err != nil {
+			return nil, v.logs, v.error(fmt.Errorf("'Encoder' returned an invalid byte array: %v", err))
+		}
+		return &output, v.logs, nil
+	}
+
+	if encodeDownlink := v.function("encodeDownlink"); encodeDownlink != nil {
+		input := v.NewObject()
+		input.Set("data", object)
+		input.Set("fPort", port)
+		result, err := v.call(func() (goja.Value, error) {
+			return encodeDownlink(goja.Undefined(), input)
+		})
+		if err != nil {
+			return nil, v.logs, v.error(err)
+		}
+		if err := v.export(result, &output); err != nil {
+			return nil, v.logs, v.error(fmt.Errorf("'encodeDownlink' returned an invalid object: %v", err))
+		}
+		if output.FPort == 0 {
+			output.FPort = fPort
+		}
+		return &output, v.logs, nil
+	}
 
-	return errNotImplemented
+	return nil, v.logs, v.error(fmt.Errorf("missing 'Encoder' or 'encodeDownlink' function"))
 }
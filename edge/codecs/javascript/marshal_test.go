@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+// deviceStore serves one device to MarshalDevice.
+type deviceStore struct {
+	edge.DeviceStore
+	device *edge.Device
+}
+
+func (s deviceStore) GetDevice(string) (*edge.Device, error) {
+	return s.device, nil
+}
+
+func TestMarshalDevice(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		script string
+		fPort  string
+	}{
+		{"Encoder", `function Encoder(object, fPort) {
+			return [object.valve ? 1 : 0, fPort];
+		}`, "2"},
+		{"encodeDownlink", `function encodeDownlink(input) {
+			return {bytes: [input.data.valve ? 1 : 0, input.fPort], fPort: 9};
+		}`, "9"},
+	}
+
+	device := &edge.Device{
+		ID: "d1",
+		Actuators: []*edge.Actuator{
+			{ID: "valve", Value: true},
+		},
+	}
+
+	for _, test := range tests {
+		script := &edge.ScriptCodec{ID: test.name, Name: test.name, Script: test.script}
+		headers := http.Header{"X-Lorawan-Fport": {"2"}}
+		var buf bytes.Buffer
+		w := &edge.DownlinkWriter{Writer: &buf}
+		if err := (JavaScriptExecutor{}).MarshalDevice(script, deviceStore{device: device}, device.ID, headers, w); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), []byte{1, 2}) {
+			t.Errorf("%s: got payload %v, want [1 2]", test.name, buf.Bytes())
+		}
+		if fPort := w.Header().Get("X-LoRaWAN-FPort"); fPort != test.fPort {
+			t.Errorf("%s: got fPort %q, want %q", test.name, fPort, test.fPort)
+		}
+		if fPort := headers.Get("X-LoRaWAN-FPort"); fPort != "2" {
+			t.Errorf("%s: the request headers were changed to fPort %q", test.name, fPort)
+		}
+	}
+}
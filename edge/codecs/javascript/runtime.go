@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/dop251/goja"
+)
+
+// Timeout is the max. execution time of one script call,
+// if the codec has no timeout set.
+var Timeout = time.Second
+
+// MaxCallStackSize is the max. depth of nested function calls of a script,
+// so endless recursions fail before the timeout.
+var MaxCallStackSize = 1024
+
+// MemoryLimit is the max. memory in bytes that one script call may allocate,
+// if the codec has no memory limit set.
+var MemoryLimit uint64 = 32 << 20
+
+// memoryCheckInterval is how often a running script is checked against its memory limit.
+const memoryCheckInterval = 10 * time.Millisecond
+
+var errTimeout = fmt.Errorf("the script did not finish in time")
+var errMemoryLimit = fmt.Errorf("the script exceeded the memory limit")
+var errStackOverflow = fmt.Errorf("the script exceeded the max. call stack size")
+
+// program is a compiled script codec.
+type program struct {
+	source   string
+	prog     *goja.Program
+	lastUsed time.Time
+}
+
+// maxPrograms is the max. number of compiled scripts in the cache.
+const maxPrograms = 64
+
+// programs caches the compiled scripts per ScriptCodec.ID and version.
+// The least recently used script is removed if the cache is full.
+var programs = map[string]*program{}
+var programsMutex sync.Mutex
+
+// compile returns the compiled script, using the cache if the script did not change.
+func compile(script *edge.ScriptCodec) (*goja.Program, error) {
+	programsMutex.Lock()
+	defer programsMutex.Unlock()
+
+	key := script.ID + "@" + strconv.Itoa(script.Version)
+	if p, ok := programs[key]; ok && p.source == script.Script {
+		p.lastUsed = time.Now()
+		return p.prog, nil
+	}
+	prog, err := goja.Compile(script.Name, script.Script, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := programs[key]; !ok && len(programs) >= maxPrograms {
+		var oldest string
+		for k, p := range programs {
+			if oldest == "" || p.lastUsed.Before(programs[oldest].lastUsed) {
+				oldest = k
+			}
+		}
+		delete(programs, oldest)
+	}
+	programs[key] = &program{
+		source:   script.Script,
+		prog:     prog,
+		lastUsed: time.Now(),
+	}
+	return prog, nil
+}
+
+// vm is a JavaScript runtime for one script call.
+type vm struct {
+	*goja.Runtime
+	script      *edge.ScriptCodec
+	logs        []string
+	timeout     time.Duration
+	memoryLimit uint64
+}
+
+// newVM creates a new runtime and runs the (compiled) script in it,
+// so that all functions of the script are defined.
+func newVM(script *edge.ScriptCodec) (*vm, error) {
+
+	prog, err := compile(script)
+	if err != nil {
+		return nil, edge.NewErrorf(500, "Err compiling script: %v", err)
+	}
+
+	v := &vm{
+		Runtime:     goja.New(),
+		script:      script,
+		timeout:     Timeout,
+		memoryLimit: MemoryLimit,
+	}
+	if script.Timeout != "" {
+		if timeout, err := time.ParseDuration(script.Timeout); err == nil {
+			v.timeout = timeout
+		}
+	}
+	if script.MemoryLimit != 0 {
+		v.memoryLimit = script.MemoryLimit
+	}
+	v.SetMaxCallStackSize(MaxCallStackSize)
+	v.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+
+	console := v.NewObject()
+	console.Set("log", v.logFunc(""))
+	console.Set("info", v.logFunc(""))
+	console.Set("debug", v.logFunc(""))
+	console.Set("warn", v.logFunc("warning: "))
+	console.Set("error", v.logFunc("error: "))
+	v.Set("console", console)
+	v.Set("print", v.logFunc(""))
+
+	if _, err := v.call(func() (goja.Value, error) {
+		return v.RunProgram(prog)
+	}); err != nil {
+		return nil, v.error(err)
+	}
+	return v, nil
+}
+
+func (v *vm) logFunc(prefix string) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		args := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			if obj, ok := arg.(*goja.Object); ok && obj.ClassName() != "Function" && obj.ClassName() != "Error" {
+				if str, err := v.stringify(obj); err == nil {
+					args[i] = str
+					continue
+				}
+			}
+			args[i] = arg.String()
+		}
+		line := prefix + strings.Join(args, " ")
+		v.logs = append(v.logs, line)
+		log.Printf("[CODEC] %s: %s", v.script.Name, line)
+		return goja.Undefined()
+	}
+}
+
+// allocated returns the bytes allocated on the heap since the program started.
+func allocated() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// call runs f with the time and memory limit.
+// The memory limit is checked against the heap allocations made during the call,
+// so allocations of other goroutines at the same time count for the script, too.
+func (v *vm) call(f func() (goja.Value, error)) (goja.Value, error) {
+
+	v.ClearInterrupt()
+	timeout := time.AfterFunc(v.timeout, func() {
+		v.Interrupt(errTimeout)
+	})
+	defer timeout.Stop()
+
+	start := allocated()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if allocated()-start > v.memoryLimit {
+					v.Interrupt(errMemoryLimit)
+					return
+				}
+			}
+		}
+	}()
+
+	value, err := f()
+	if err == nil && allocated()-start > v.memoryLimit {
+		err = errMemoryLimit
+	}
+	if err != nil {
+		if interrupted, ok := err.(*goja.InterruptedError); ok {
+			return nil, fmt.Errorf("%v", interrupted.Value())
+		}
+		if _, ok := err.(*goja.StackOverflowError); ok {
+			return nil, errStackOverflow
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// error wraps the script error with the logs of this runtime.
+func (v *vm) error(err error) error {
+	if len(v.logs) == 0 {
+		return edge.NewErrorf(500, "Err executing script: %v", err)
+	}
+	return edge.NewErrorf(500, "Err executing script: %v\n%s", err, prefix("> ", strings.Join(v.logs, "\n")))
+}
+
+// function returns the global function with that name or nil.
+func (v *vm) function(name string) goja.Callable {
+	fn, _ := goja.AssertFunction(v.Get(name))
+	return fn
+}
+
+// stringify returns JSON.stringify(value).
+func (v *vm) stringify(value goja.Value) (string, error) {
+	stringify, _ := goja.AssertFunction(v.Get("JSON").ToObject(v.Runtime).Get("stringify"))
+	str, err := stringify(goja.Undefined(), value)
+	if err != nil {
+		return "", err
+	}
+	return str.String(), nil
+}
+
+// export converts the JS value to Go using its JSON representation.
+func (v *vm) export(value goja.Value, i interface{}) error {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return fmt.Errorf("the function returned %v", value)
+	}
+	str, err := v.stringify(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(str), i)
+}
+
+// bytes creates a JS Uint8Array with that data.
+func (v *vm) bytes(data []byte) goja.Value {
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	arr, _ := v.New(v.Get("Uint8Array"), v.ToValue(v.NewArrayBuffer(buf)))
+	return arr
+}
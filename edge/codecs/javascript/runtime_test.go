@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func TestMemoryLimit(t *testing.T) {
+
+	script := &edge.ScriptCodec{
+		ID:          "memory",
+		Name:        "memory",
+		Timeout:     "10s",
+		MemoryLimit: 1 << 20,
+		Script: `function Decoder(bytes, fPort) {
+			var arrays = [];
+			while (true) {
+				arrays.push(new Array(1000).fill(fPort));
+			}
+		}`,
+	}
+	_, _, err := Decode(script, nil, 1)
+	if err == nil || !strings.Contains(err.Error(), errMemoryLimit.Error()) {
+		t.Fatalf("got error %v, want %q", err, errMemoryLimit)
+	}
+
+	script = &edge.ScriptCodec{
+		ID:          "small",
+		Name:        "small",
+		MemoryLimit: 1 << 20,
+		Script:      `function Decoder(bytes, fPort) { return {port: fPort}; }`,
+	}
+	if _, _, err := Decode(script, nil, 1); err != nil {
+		t.Fatalf("a small script failed: %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"Decoder", `function Decoder(bytes, fPort) {
+			return {temperature: bytes[0], port: fPort};
+		}`},
+		{"Decode", `function Decode(fPort, bytes) {
+			return {temperature: bytes[0], port: fPort};
+		}`},
+		{"decodeUplink", `function decodeUplink(input) {
+			return {data: {temperature: input.bytes[0], port: input.fPort}};
+		}`},
+	}
+
+	for _, test := range tests {
+		script := &edge.ScriptCodec{ID: test.name, Name: test.name, Script: test.script}
+		output, _, err := Decode(script, []byte{21}, 7)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if output.Data["temperature"] != 21.0 || output.Data["port"] != 7.0 {
+			t.Errorf("%s: got %v, want temperature 21 and port 7", test.name, output.Data)
+		}
+	}
+}
+
+func TestConsoleLog(t *testing.T) {
+
+	script := &edge.ScriptCodec{
+		ID:   "console",
+		Name: "console",
+		Script: `function Decoder(bytes, fPort) {
+			console.log("port", fPort, {a: 1});
+			console.warn("careful");
+			return {};
+		}`,
+	}
+	_, logs, err := Decode(script, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`port 2 {"a":1}`, "warning: careful"}
+	if strings.Join(logs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got logs %q, want %q", logs, want)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+
+	script := &edge.ScriptCodec{
+		ID:      "timeout",
+		Name:    "timeout",
+		Timeout: "100ms",
+		Script:  `function Decoder(bytes, fPort) { while(true){} }`,
+	}
+	start := time.Now()
+	_, _, err := Decode(script, nil, 1)
+	if err == nil || !strings.Contains(err.Error(), errTimeout.Error()) {
+		t.Fatalf("got error %v, want %q", err, errTimeout)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("the script was stopped after %v, want 100ms", d)
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+
+	decode := func(script *edge.ScriptCodec) interface{} {
+		output, _, err := Decode(script, nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output.Data["version"]
+	}
+
+	script := &edge.ScriptCodec{
+		ID:      "cached",
+		Name:    "cached",
+		Version: 1,
+		Script:  `function Decoder(bytes, fPort) { return {version: 1}; }`,
+	}
+	if v := decode(script); v != 1.0 {
+		t.Fatalf("version 1: got %v", v)
+	}
+	if v := decode(script); v != 1.0 {
+		t.Fatalf("version 1 (cached): got %v", v)
+	}
+
+	script.Version = 2
+	script.Script = `function Decoder(bytes, fPort) { return {version: 2}; }`
+	if v := decode(script); v != 2.0 {
+		t.Errorf("version 2: got %v", v)
+	}
+	if _, ok := programs["cached@2"]; !ok {
+		t.Errorf("version 2 is not cached as cached@2")
+	}
+
+	// the same version with another script (like an unsaved codec test) is compiled again
+	script.Script = `function Decoder(bytes, fPort) { return {version: 3}; }`
+	if v := decode(script); v != 3.0 {
+		t.Errorf("changed script: got %v", v)
+	}
+}
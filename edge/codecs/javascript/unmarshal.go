@@ -1,65 +1,27 @@
 package executor
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/dop251/goja"
 )
 
 type DecoderOutput struct {
 	Data     map[string]any `json:"data"`
 	Warnings []string       `json:"warnings"`
 	Errors   []string       `json:"errors"`
+	// Commands are reports about actuator commands, like acknowledgements in the uplink.
+	Commands []edge.CommandReport `json:"commands"`
 }
 
-var errNodeUnavaliable = edge.NewError(500, "the Node.js 'node' cmd was not found")
-var errNoOutput = edge.NewError(500, "the script exited prematurely without producing any output")
-var errInvalidOutput = edge.NewError(500, "the script exited prematurely")
-
-const scriptDeadline = 0 // time.Second // 200 * time.Millisecond // 0
-
-const scriptFooter1 = `
-//*/
-function main() {
-  const bytes = new Uint8Array([`
-const scriptFooter2 = `]);
-  const fPort = `
-const scriptFooter3 = `;
-
-  if(typeof Decoder === "function") {
-	const o = Decoder(bytes, fPort);
-    print("\n$!\x01"+JSON.stringify(o));
-    return;
-  }
-  if(typeof Decode === "function") {
-	const o = Decode(fPort, bytes);
-    print("\n$!\x01"+JSON.stringify(o));
-    return;
-  }
-  if(typeof decodeUplink ==="function") {
-	const o = decodeUplink({fPort,bytes});
-	print("\n$!\x02"+JSON.stringify(o));
-  }
-  
-
-  print("\n$!\x04");
-}
-main();`
-
-var outRegexp = regexp.MustCompile(`\n\$\!.+\n`)
-
-func PostSensorValues(output DecoderOutput, script *edge.ScriptCodec, device *edge.Device, deviceID string) error {
+func PostSensorValues(store edge.DeviceStore, output DecoderOutput, script *edge.ScriptCodec, device *edge.Device, deviceID string) error {
 	now := time.Now()
 	// // TEST
 	// output = DecoderOutput{
@@ -76,7 +38,7 @@ OUTPUT:
 		for _, sensor := range device.Sensors {
 			if sensor.ID == sensorID {
 				// Sensor values
-				_, err := edge.PostSensorValue(deviceID, sensorID, edge.NewValue(value, now))
+				_, err := store.PostSensorValue(deviceID, sensorID, edge.NewValue(value, now))
 				if err != nil {
 					return edge.NewErrorf(500, "Can not create sensor value: %s", err)
 				}
@@ -85,14 +47,14 @@ OUTPUT:
 					"Warnings": strings.Join(output.Warnings, " "),
 					"Errors":   strings.Join(output.Errors, " "),
 				}
-				err = edge.SetSensorMeta(deviceID, sensorID, metadata)
+				err = store.SetSensorMeta(deviceID, sensorID, metadata)
 				if err != nil {
 					return edge.NewErrorf(500, "Can not set sensor meta: %s", err)
 				}
 				continue OUTPUT
 			}
 		}
-		err := edge.PostSensor(deviceID, &edge.Sensor{
+		err := store.PostSensor(deviceID, &edge.Sensor{
 			ID:   sensorID,
 			Name: sensorID,
 			Meta: edge.Meta{
@@ -109,110 +71,94 @@ OUTPUT:
 	return nil
 }
 
-func (JavaScriptExecutor) UnmarshalDevice(script *edge.ScriptCodec, deviceID string, headers http.Header, r io.Reader) error {
+func (JavaScriptExecutor) UnmarshalDevice(script *edge.ScriptCodec, store edge.DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
 
-	device, err := edge.GetDevice(deviceID)
+	device, err := store.GetDevice(deviceID)
 	if err != nil {
 		return edge.NewError(404, "no device with that id")
 	}
 
-	ctx := context.Background()
-	if scriptDeadline != 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, time.Second)
-		defer cancel()
-	}
-
-	tempScript, err := os.CreateTemp("", "codec")
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(tempScript.Name())
+	// will be '0' on any error
+	port, _ := strconv.Atoi(headers.Get("X-LoRaWAN-FPort"))
 
-	tempScript.WriteString(script.Script)
-	tempScript.WriteString(scriptFooter1)
-	data, err := ioutil.ReadAll(r)
+	output, logs, err := Decode(script, data, port)
 	if err != nil {
+		store.Report(deviceID, nil, nil, logs)
 		return err
 	}
-	for i, d := range data {
-		if i != 0 {
-			tempScript.WriteString(", ")
-		}
-		tempScript.WriteString(strconv.Itoa(int(d)))
+	store.Report(deviceID, output.Warnings, output.Errors, logs)
+	if err := PostSensorValues(store, *output, script, device, deviceID); err != nil {
+		return edge.NewErrorf(500, "Err posting unmarshaled values to wazigate-edge:\n> %s", err)
 	}
+	store.ReportCommands(deviceID, output.Commands)
+	return nil
+}
 
-	tempScript.WriteString(scriptFooter2)
-
-	port, _ := strconv.Atoi(headers.Get("X-LoRaWAN-FPort"))
-	// will be '0' on any error
-	tempScript.WriteString(strconv.Itoa(port))
-	tempScript.WriteString(scriptFooter3)
+// Decode runs the decoder of the script with that payload.
+// The script can use the TTN conventions `Decoder(bytes, fPort)`, `Decode(fPort, bytes)`
+// or `decodeUplink({bytes, fPort})`. This also returns everything logged by the script.
+func Decode(script *edge.ScriptCodec, data []byte, fPort int) (*DecoderOutput, []string, error) {
 
-	if err := tempScript.Close(); err != nil {
-		return err
+	v, err := newVM(script)
+	if err != nil {
+		return nil, nil, err
 	}
+	bytes := v.bytes(data)
+	port := v.ToValue(fPort)
 
-	cmd := exec.CommandContext(ctx, "qjs", "--script", tempScript.Name())
-	var stdout bytes.Buffer
-	cmd.Stderr = &stdout
-	cmd.Stdout = &stdout
+	var output DecoderOutput
 
-	if err := cmd.Run(); err != nil {
-		if err == exec.ErrNotFound {
-			return errNodeUnavaliable
+	if decoder := v.function("Decoder"); decoder != nil {
+		result, err := v.call(func() (goja.Value, error) {
+			return decoder(goja.Undefined(), bytes, port)
+		})
+		if err != nil {
+			return nil, v.logs, v.error(err)
 		}
-		if exitError, ok := err.(*exec.ExitError); ok {
-			return edge.NewErrorf(500, "the 'node' cmd returned with status %d:\n%s", exitError.ExitCode(), prefix("> ", stdout.String()))
+		if err := v.export(result, &output.Data); err != nil {
+			return nil, v.logs, v.error(fmt.Errorf("'Decoder' returned an invalid object: %v", err))
 		}
-		return edge.NewErrorf(500, "exec failed: %s", err.Error())
+		return &output, v.logs, nil
 	}
-	if stdout.Len() == 0 {
-		return errNoOutput
-	}
-	log := stdout.Bytes()
-	i := outRegexp.FindIndex(log)
-	if i == nil {
-		return edge.NewErrorf(500, "Err executing script: The script exited prematurely\n%s", prefix("> ", string(log)))
-	}
-	match := log[i[0]:i[1]]
-	log = append(log[:i[0]], log[i[1]:]...)
-
-	switch match[3] {
-	case 0x03:
-		typeOfDecoder := string(match[4 : len(match)-1])
-		if typeOfDecoder == "undefined" {
-			return edge.NewErrorf(500, "Err executing script: Missing 'Decoder' function.\n%s", prefix("> ", string(log)))
-		}
-		return edge.NewErrorf(500, "Err executing script: 'Decoder' is not a function, it's '%s'.\n%s", typeOfDecoder, prefix("> ", string(log)))
-
-	// Decode, Decoder
-	case 0x01:
-		outputJSON := match[4 : len(match)-1]
-		var data = map[string]interface{}{}
 
-		if err := json.Unmarshal(outputJSON, &data); err != nil {
-			return edge.NewErrorf(500, "Err executing script: 'Decoder' returned an invalid object:\n> %s.\n%s", outputJSON, prefix("> ", string(log)))
+	if decode := v.function("Decode"); decode != nil {
+		result, err := v.call(func() (goja.Value, error) {
+			return decode(goja.Undefined(), port, bytes)
+		})
+		if err != nil {
+			return nil, v.logs, v.error(err)
 		}
-		if PostSensorValues(DecoderOutput{Data: data}, script, device, deviceID); err != nil {
-			return edge.NewErrorf(500, "Err posting unmarshaled values to wazigate-edge:\n> %s.\n%s", outputJSON, prefix("> ", string(log)))
+		if err := v.export(result, &output.Data); err != nil {
+			return nil, v.logs, v.error(fmt.Errorf("'Decode' returned an invalid object: %v", err))
 		}
+		return &output, v.logs, nil
+	}
 
-	// DecodeUplink
-	case 0x02:
-		outputJSON := match[4 : len(match)-1]
-		var output DecoderOutput
-		if err := json.Unmarshal(outputJSON, &output); err != nil {
-			return edge.NewErrorf(500, "Err executing script: 'Decoder' returned an invalid object:\n> %s.\n%s", outputJSON, prefix("> ", string(log)))
+	if decodeUplink := v.function("decodeUplink"); decodeUplink != nil {
+		input := v.NewObject()
+		input.Set("bytes", bytes)
+		input.Set("fPort", port)
+		result, err := v.call(func() (goja.Value, error) {
+			return decodeUplink(goja.Undefined(), input)
+		})
+		if err != nil {
+			return nil, v.logs, v.error(err)
 		}
-		if PostSensorValues(output, script, device, deviceID); err != nil {
-			return edge.NewErrorf(500, "Err posting unmarshaled values to wazigate-edge:\n> %s.\n%s", outputJSON, prefix("> ", string(log)))
+		if err := v.export(result, &output); err != nil {
+			return nil, v.logs, v.error(fmt.Errorf("'decodeUplink' returned an invalid object: %v", err))
 		}
+		return &output, v.logs, nil
+	}
 
-	default:
-		return edge.NewErrorf(500, "Err executing script: Unknown code 0x%02x.\n%s", prefix("> ", stdout.String()))
+	if v.Get("Decoder") != nil {
+		typeOfDecoder, _ := v.RunString("typeof Decoder")
+		return nil, v.logs, v.error(fmt.Errorf("'Decoder' is not a function, it's '%s'", typeOfDecoder))
 	}
-	return nil
+	return nil, v.logs, v.error(fmt.Errorf("missing 'Decoder', 'Decode' or 'decodeUplink' function"))
 }
 
 func prefix(prefix string, str string) string {
@@ -18,9 +18,9 @@ func (JSONCodec) CodecName() string {
 	return "JSON"
 }
 
-func (JSONCodec) MarshalDevice(deviceID string, headers http.Header, w io.Writer) error {
+func (JSONCodec) MarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
 
-	device, err := edge.GetDevice(deviceID)
+	device, err := store.GetDevice(deviceID)
 	if err != nil {
 		return err
 	}
@@ -29,9 +29,9 @@ func (JSONCodec) MarshalDevice(deviceID string, headers http.Header, w io.Writer
 	return encoder.Encode(device)
 }
 
-func (JSONCodec) UnmarshalDevice(deviceID string, headers http.Header, r io.Reader) error {
+func (JSONCodec) UnmarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
 
-	device, err := edge.GetDevice(deviceID)
+	device, err := store.GetDevice(deviceID)
 	if err != nil {
 		return err
 	}
@@ -50,7 +50,7 @@ DEVIC2_SENSORS:
 				continue DEVIC2_SENSORS
 			}
 		}
-		if err = edge.PostSensor(deviceID, sensor2); err != nil {
+		if err = store.PostSensor(deviceID, sensor2); err != nil {
 			return err
 		}
 	}
@@ -61,7 +61,7 @@ DEVIC2_ACTUATORS:
 				continue DEVIC2_ACTUATORS
 			}
 		}
-		if err = edge.PostActuator(deviceID, actuators2); err != nil {
+		if err = store.PostActuator(deviceID, actuators2); err != nil {
 			return err
 		}
 	}
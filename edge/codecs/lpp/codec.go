@@ -0,0 +1,17 @@
+package codec
+
+import (
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func init() {
+	edge.Codecs["application/x-lpp"] = LPPCodec{}
+}
+
+func (LPPCodec) CodecName() string {
+	return "LPP (Cayenne Low Power Payload)"
+}
+
+// LPPCodec implements the standard Cayenne Low Power Payload.
+// Unlike XLPP, there is no delay type: all values of one payload share the time of the upload.
+type LPPCodec struct{}
@@ -0,0 +1,222 @@
+package codec
+
+type def struct {
+	Kind     string
+	Quantity string
+	Unit     string
+}
+
+// field is one number of an LPP value, big endian.
+type field struct {
+	Name       string
+	Size       int
+	Signed     bool
+	Resolution float64
+}
+
+type lppType struct {
+	Name   string
+	Fields []field
+	// Output types announce actuators.
+	Output bool
+	Sensor def
+}
+
+func scalar(size int, signed bool, resolution float64) []field {
+	return []field{{Size: size, Signed: signed, Resolution: resolution}}
+}
+
+func xyz(size int, resolution float64) []field {
+	return []field{
+		{Name: "X", Size: size, Signed: true, Resolution: resolution},
+		{Name: "Y", Size: size, Signed: true, Resolution: resolution},
+		{Name: "Z", Size: size, Signed: true, Resolution: resolution},
+	}
+}
+
+const (
+	TypeDigitalInput       = 0
+	TypeDigitalOutput      = 1
+	TypeAnalogInput        = 2
+	TypeAnalogOutput       = 3
+	TypeGenericSensor      = 100
+	TypeLuminosity         = 101
+	TypePresence           = 102
+	TypeTemperature        = 103
+	TypeRelativeHumidity   = 104
+	TypeAccelerometer      = 113
+	TypeBarometricPressure = 115
+	TypeVoltage            = 116
+	TypeCurrent            = 117
+	TypeFrequency          = 118
+	TypePercentage         = 120
+	TypeAltitude           = 121
+	TypeConcentration      = 125
+	TypePower              = 128
+	TypeDistance           = 130
+	TypeEnergy             = 131
+	TypeDirection          = 132
+	TypeUnixTime           = 133
+	TypeGyrometer          = 134
+	TypeColour             = 135
+	TypeGPS                = 136
+	TypeSwitch             = 142
+)
+
+var types = map[byte]lppType{
+	TypeDigitalInput: {
+		Name:   "digitalInput",
+		Fields: scalar(1, false, 1),
+		Sensor: def{Quantity: "DigitalValue"},
+	},
+	TypeDigitalOutput: {
+		Name:   "digitalOutput",
+		Fields: scalar(1, false, 1),
+		Output: true,
+		Sensor: def{Quantity: "DigitalValue"},
+	},
+	TypeAnalogInput: {
+		Name:   "analogInput",
+		Fields: scalar(2, true, 0.01),
+		Sensor: def{Quantity: "AnalogeValue"},
+	},
+	TypeAnalogOutput: {
+		Name:   "analogOutput",
+		Fields: scalar(2, true, 0.01),
+		Output: true,
+		Sensor: def{Quantity: "AnalogeValue"},
+	},
+	TypeGenericSensor: {
+		Name:   "genericSensor",
+		Fields: scalar(4, false, 1),
+	},
+	TypeLuminosity: {
+		Name:   "luminosity",
+		Fields: scalar(2, false, 1),
+		Sensor: def{Kind: "LightSensor", Quantity: "Illuminance", Unit: "Lux"},
+	},
+	TypePresence: {
+		Name:   "presence",
+		Fields: scalar(1, false, 1),
+		Sensor: def{Kind: "HumanPresenceDetector", Quantity: "Presence"},
+	},
+	TypeTemperature: {
+		Name:   "temperature",
+		Fields: scalar(2, true, 0.1),
+		Sensor: def{Kind: "Thermometer", Quantity: "Temperature", Unit: "DegreeCelsius"},
+	},
+	TypeRelativeHumidity: {
+		Name:   "relativeHumidity",
+		Fields: scalar(1, false, 0.5),
+		Sensor: def{Kind: "HumiditySensor", Quantity: "RelativeHumidity", Unit: "Percent"},
+	},
+	TypeAccelerometer: {
+		Name:   "accelerometer",
+		Fields: xyz(2, 0.001),
+		Sensor: def{Kind: "Accelerometer", Quantity: "Acceleration", Unit: "MetrePerSecondSquare"},
+	},
+	TypeBarometricPressure: {
+		Name:   "barometricPressure",
+		Fields: scalar(2, false, 0.1),
+		Sensor: def{Kind: "PressureSensor", Quantity: "AtmosphericPressure", Unit: "Hectopascal"},
+	},
+	TypeVoltage: {
+		Name:   "voltage",
+		Fields: scalar(2, false, 0.01),
+		Sensor: def{Kind: "VoltageSensor", Quantity: "Voltage", Unit: "Volt"},
+	},
+	TypeCurrent: {
+		Name:   "current",
+		Fields: scalar(2, false, 0.001),
+		Sensor: def{Kind: "ElectricalSensor", Quantity: "ElectricCurrent", Unit: "Ampere"},
+	},
+	TypeFrequency: {
+		Name:   "frequency",
+		Fields: scalar(4, false, 1),
+		Sensor: def{Kind: "FrequencySensor", Quantity: "Frequency", Unit: "Hertz"},
+	},
+	TypePercentage: {
+		Name:   "percentage",
+		Fields: scalar(1, false, 1),
+		Sensor: def{Unit: "Percent"},
+	},
+	TypeAltitude: {
+		Name:   "altitude",
+		Fields: scalar(2, true, 1),
+		Sensor: def{Quantity: "Altitude", Unit: "Metre"},
+	},
+	TypeConcentration: {
+		Name:   "concentration",
+		Fields: scalar(2, false, 1),
+		Sensor: def{Kind: "GaseousPollutantSensor", Quantity: "ChemicalAgentConcentration", Unit: "PartsPerMillion"},
+	},
+	TypePower: {
+		Name:   "power",
+		Fields: scalar(2, false, 1),
+		Sensor: def{Kind: "ElectricalSensor", Quantity: "ActivePower", Unit: "Watt"},
+	},
+	TypeDistance: {
+		Name:   "distance",
+		Fields: scalar(4, false, 0.001),
+		Sensor: def{Kind: "DistanceSensor", Quantity: "Distance", Unit: "Metre"},
+	},
+	TypeEnergy: {
+		Name:   "energy",
+		Fields: scalar(4, false, 0.001),
+		Sensor: def{Kind: "EnergyMeter", Quantity: "Energy", Unit: "KiloWattHour"},
+	},
+	TypeDirection: {
+		Name:   "direction",
+		Fields: scalar(2, false, 1),
+		Sensor: def{Kind: "WindDirectionSensor", Quantity: "WindDirection", Unit: "DegreeAngle"},
+	},
+	TypeUnixTime: {
+		Name:   "unixTime",
+		Fields: scalar(4, false, 1),
+		Sensor: def{Kind: "Clock", Quantity: "Timestamp", Unit: "SecondTime"},
+	},
+	TypeGyrometer: {
+		Name:   "gyrometer",
+		Fields: xyz(2, 0.01),
+		Sensor: def{Kind: "GyroscopeSensor", Quantity: "RotationalSpeed", Unit: "DegreeAnglePerSecond"},
+	},
+	TypeColour: {
+		Name: "colour",
+		Fields: []field{
+			{Name: "R", Size: 1, Resolution: 1},
+			{Name: "G", Size: 1, Resolution: 1},
+			{Name: "B", Size: 1, Resolution: 1},
+		},
+		Sensor: def{Quantity: "Color"},
+	},
+	TypeGPS: {
+		Name: "gps",
+		Fields: []field{
+			{Name: "Latitude", Size: 3, Signed: true, Resolution: 0.0001},
+			{Name: "Longitude", Size: 3, Signed: true, Resolution: 0.0001},
+			{Name: "Meters", Size: 3, Signed: true, Resolution: 0.01},
+		},
+		Sensor: def{Kind: "GPSSensor", Quantity: "Position", Unit: "LatLong"},
+	},
+	TypeSwitch: {
+		Name:   "switch",
+		Fields: scalar(1, false, 1),
+		Sensor: def{Quantity: "Boolean"},
+	},
+}
+
+// typeFromDef returns the LPP type for an actuator, preferring output types.
+func typeFromDef(quantity string, unit string) int {
+	t := -1
+	for i, d := range types {
+		if d.Sensor.Quantity == quantity && (d.Sensor.Unit == unit || d.Sensor.Unit == "") {
+			if d.Output {
+				return int(i)
+			}
+			if t == -1 || int(i) < t {
+				t = int(i)
+			}
+		}
+	}
+	return t
+}
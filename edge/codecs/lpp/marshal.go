@@ -0,0 +1,97 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+// MarshalDevice writes the actuator values as LPP downlink.
+// Actuators need the meta 'lppChan' (or 'xlppChan') and either 'lppType' or a quantity and unit known to LPP.
+func (LPPCodec) MarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, actuator := range device.Actuators {
+		channel := lppChan(actuator.Meta)
+		if channel < 0 || channel > 255 {
+			log.Printf("Err Codec LPP: Actuator %s/%s: No lppChan or xlppChan meta", device.ID, actuator.ID)
+			continue
+		}
+		if actuator.Value == nil {
+			continue
+		}
+		quantity, _ := actuator.Meta["quantity"].(string)
+		unit, _ := actuator.Meta["unit"].(string)
+		typ := metaInt(actuator.Meta, "lppType")
+		if typ == -1 {
+			typ = typeFromDef(quantity, unit)
+		}
+		t, ok := types[byte(typ)]
+		if typ < 0 || typ > 255 || !ok {
+			log.Printf("Err Codec LPP: Actuator %s/%s: No type for (quantity:%q, unit:%q)", device.ID, actuator.ID, quantity, unit)
+			continue
+		}
+		data, err := writeValue(t, actuator.Value)
+		if err != nil {
+			log.Printf("Err Codec LPP: Actuator %s/%s: Can not assign value %v to %s: %v", device.ID, actuator.ID, actuator.Value, t.Name, err)
+			continue
+		}
+		buf = append(buf, byte(channel), byte(typ))
+		buf = append(buf, data...)
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+func writeValue(t lppType, value interface{}) ([]byte, error) {
+	var fields map[string]float64
+	var scalar float64
+	switch v := value.(type) {
+	case bool:
+		if v {
+			scalar = 1
+		}
+	default:
+		d, _ := json.Marshal(value)
+		if len(t.Fields) == 1 && t.Fields[0].Name == "" {
+			if err := json.Unmarshal(d, &scalar); err != nil {
+				return nil, err
+			}
+		} else if err := json.Unmarshal(d, &fields); err != nil {
+			return nil, err
+		}
+	}
+
+	var data []byte
+	for _, f := range t.Fields {
+		v := scalar
+		if f.Name != "" {
+			v = fields[f.Name]
+		}
+		raw := int64(math.Round(v * math.Round(1/f.Resolution)))
+		max := int64(1) << (f.Size * 8)
+		min := int64(0)
+		if f.Signed {
+			max /= 2
+			min = -max
+		}
+		if raw < min || raw >= max {
+			return nil, fmt.Errorf("%v is out of range", v)
+		}
+		for i := f.Size - 1; i >= 0; i-- {
+			data = append(data, byte(raw>>(i*8)))
+		}
+	}
+	return data, nil
+}
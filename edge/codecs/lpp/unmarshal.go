@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func (LPPCodec) UnmarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	records, err := decode(data)
+	if err != nil {
+		return err
+	}
+
+	t := time.Now()
+
+	for _, r := range records {
+		lppType := types[r.Type]
+		if lppType.Output {
+			if err := createActuator(store, device, r.Channel, r.Type, lppType); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := createSensor(store, device, r.Channel, r.Type, lppType, r.Value, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record is one channel of a LPP payload.
+type record struct {
+	Channel int
+	Type    byte
+	Value   interface{}
+}
+
+// decode reads all records of the payload.
+func decode(data []byte) ([]record, error) {
+
+	var records []record
+	for len(data) != 0 {
+		if len(data) < 2 {
+			return nil, edge.NewError(400, "Err Codec LPP: unexpected end of payload")
+		}
+		channel, typ := int(data[0]), data[1]
+		lppType, ok := types[typ]
+		if !ok {
+			return nil, edge.NewErrorf(400, "Err Codec LPP: unknown type %d on channel %d", typ, channel)
+		}
+		value, n, err := readValue(lppType, data[2:])
+		if err != nil {
+			return nil, edge.NewErrorf(400, "Err Codec LPP: channel %d: %v", channel, err)
+		}
+		data = data[2+n:]
+		records = append(records, record{channel, typ, value})
+	}
+	return records, nil
+}
+
+// readValue returns a number for single field types and a map for types like GPS.
+func readValue(t lppType, data []byte) (interface{}, int, error) {
+	n := 0
+	m := make(map[string]interface{}, len(t.Fields))
+	var value interface{} = m
+	for _, f := range t.Fields {
+		if len(data) < n+f.Size {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		var raw int64
+		for _, b := range data[n : n+f.Size] {
+			raw = raw<<8 | int64(b)
+		}
+		if f.Signed && raw&(1<<(f.Size*8-1)) != 0 {
+			raw -= 1 << (f.Size * 8)
+		}
+		n += f.Size
+		v := float64(raw) / math.Round(1/f.Resolution)
+		if f.Name == "" {
+			value = v
+		} else {
+			m[f.Name] = v
+		}
+	}
+	return value, n, nil
+}
+
+func createActuator(store edge.DeviceStore, device *edge.Device, channel int, typ byte, t lppType) error {
+	for _, actuator := range device.Actuators {
+		if lppChan(actuator.Meta) == channel {
+			return nil
+		}
+	}
+	return store.PostActuator(device.ID, &edge.Actuator{
+		Name: t.Name,
+		Meta: edge.Meta{
+			"kind":      t.Sensor.Kind,
+			"quantity":  t.Sensor.Quantity,
+			"unit":      t.Sensor.Unit,
+			"lppChan":   channel,
+			"lppType":   int(typ),
+			"createdBy": "codec:lpp",
+		},
+	})
+}
+
+func createSensor(store edge.DeviceStore, device *edge.Device, channel int, typ byte, t lppType, value interface{}, time time.Time) error {
+	for _, sensor := range device.Sensors {
+		if lppChan(sensor.Meta) == channel {
+			v := edge.NewValue(value, time)
+			if _, err := store.PostSensorValue(device.ID, sensor.ID, v); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+	return store.PostSensor(device.ID, &edge.Sensor{
+		Name:  t.Name,
+		Value: value,
+		Time:  &time,
+		Meta: edge.Meta{
+			"kind":      t.Sensor.Kind,
+			"quantity":  t.Sensor.Quantity,
+			"unit":      t.Sensor.Unit,
+			"lppChan":   channel,
+			"lppType":   int(typ),
+			"createdBy": "codec:lpp",
+		},
+	})
+}
+
+// lppChan returns the channel of the sensor or actuator, or -1.
+// Devices created by the XLPP codec (used for LPP before) have the meta 'xlppChan' instead.
+func lppChan(m edge.Meta) int {
+	if c := metaInt(m, "lppChan"); c != -1 {
+		return c
+	}
+	return metaInt(m, "xlppChan")
+}
+
+func metaInt(m edge.Meta, key string) int {
+	c, ok := m[key]
+	if ok {
+		if d, ok := c.(float64); ok {
+			return int(d)
+		}
+		if d, ok := c.(int); ok {
+			return int(d)
+		}
+	}
+	return -1
+}
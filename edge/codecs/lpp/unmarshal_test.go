@@ -0,0 +1,184 @@
+package codec
+
+import (
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+// The frames are the examples of the Cayenne LPP documentation.
+func TestDecode(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		payload string
+		records []record
+	}{
+		{
+			name:    "two temperatures",
+			payload: "03670110056700ff",
+			records: []record{
+				{3, TypeTemperature, 27.2},
+				{5, TypeTemperature, 25.5},
+			},
+		},
+		{
+			name:    "accelerometer",
+			payload: "067104d2fb2e0000",
+			records: []record{
+				{6, TypeAccelerometer, map[string]interface{}{"X": 1.234, "Y": -1.234, "Z": 0.0}},
+			},
+		},
+		{
+			name:    "gps",
+			payload: "018806765ff2960a0003e8",
+			records: []record{
+				{1, TypeGPS, map[string]interface{}{"Latitude": 42.3519, "Longitude": -87.9094, "Meters": 10.0}},
+			},
+		},
+		{
+			name:    "digital output",
+			payload: "020101",
+			records: []record{
+				{2, TypeDigitalOutput, 1.0},
+			},
+		},
+		{
+			name:    "empty",
+			payload: "",
+			records: nil,
+		},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.payload)
+		records, err := decode(data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(records) != len(test.records) {
+			t.Errorf("%s: got %d records, want %d", test.name, len(records), len(test.records))
+			continue
+		}
+		for i, r := range records {
+			want := test.records[i]
+			if r.Channel != want.Channel || r.Type != want.Type || !approxEqual(r.Value, want.Value) {
+				t.Errorf("%s: record %d is %+v, want %+v", test.name, i, r, want)
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+
+	for _, payload := range []string{
+		"03",       // no type
+		"036701",   // temperature too short
+		"03ff0000", // unknown type
+	} {
+		data, _ := hex.DecodeString(payload)
+		if _, err := decode(data); err == nil {
+			t.Errorf("%s: no error", payload)
+		}
+	}
+}
+
+func TestWriteValue(t *testing.T) {
+
+	tests := []struct {
+		typ   byte
+		value interface{}
+		data  string
+	}{
+		{TypeDigitalOutput, true, "01"},
+		{TypeDigitalOutput, 0.0, "00"},
+		{TypeAnalogOutput, -1.5, "ff6a"},
+		{TypeTemperature, 27.2, "0110"},
+		{TypeColour, map[string]interface{}{"R": 255, "G": 0, "B": 16}, "ff0010"},
+	}
+
+	for _, test := range tests {
+		data, err := writeValue(types[test.typ], test.value)
+		if err != nil {
+			t.Errorf("%v: %v", test.value, err)
+			continue
+		}
+		if hex.EncodeToString(data) != test.data {
+			t.Errorf("%v: got %x, want %s", test.value, data, test.data)
+		}
+	}
+
+	if _, err := writeValue(types[TypeDigitalOutput], 256.0); err == nil {
+		t.Errorf("256 as digital output: no error")
+	}
+}
+
+func TestLPPChan(t *testing.T) {
+
+	tests := []struct {
+		meta    edge.Meta
+		channel int
+	}{
+		{edge.Meta{"lppChan": 3}, 3},
+		{edge.Meta{"lppChan": 3.0}, 3},
+		// created by the XLPP codec
+		{edge.Meta{"xlppChan": 5.0}, 5},
+		{edge.Meta{"lppChan": 1.0, "xlppChan": 5.0}, 1},
+		{edge.Meta{}, -1},
+		{nil, -1},
+	}
+
+	for _, test := range tests {
+		if c := lppChan(test.meta); c != test.channel {
+			t.Errorf("%v: got channel %d, want %d", test.meta, c, test.channel)
+		}
+	}
+}
+
+func approxEqual(a, b interface{}) bool {
+	if fa, ok := a.(float64); ok {
+		fb, ok := b.(float64)
+		return ok && math.Abs(fa-fb) < 1e-9
+	}
+	ma, ok := a.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(a, b)
+	}
+	mb, ok := b.(map[string]interface{})
+	if !ok || len(ma) != len(mb) {
+		return false
+	}
+	for key, value := range ma {
+		if !approxEqual(value, mb[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestCodec runs without the database: the values go to the scratch device only.
+func TestTestCodec(t *testing.T) {
+
+	data, _ := hex.DecodeString("03670110056700ff")
+	device := &edge.Device{
+		Sensors: []*edge.Sensor{{ID: "temp", Meta: edge.Meta{"lppChan": 3.0}}},
+	}
+	result := edge.TestCodec(LPPCodec{}, device, nil, data)
+
+	if len(result.Errors) != 0 {
+		t.Fatalf("errors: %v", result.Errors)
+	}
+	if len(result.Sensors) != 2 {
+		t.Fatalf("got %d sensors, want 2", len(result.Sensors))
+	}
+	if result.Sensors[0].ID != "temp" || !approxEqual(result.Sensors[0].Value, 27.2) {
+		t.Errorf("sensor 0 is %+v, want temp = 27.2", result.Sensors[0])
+	}
+	if !approxEqual(result.Sensors[1].Value, 25.5) || lppChan(result.Sensors[1].Meta) != 5 {
+		t.Errorf("sensor 1 is %+v, want channel 5 = 25.5", result.Sensors[1])
+	}
+}
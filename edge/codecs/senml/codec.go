@@ -0,0 +1,22 @@
+package codec
+
+import (
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func init() {
+	edge.Codecs["application/senml+json"] = SenMLCodec{}
+	edge.Codecs["application/senml+cbor"] = SenMLCodec{CBOR: true}
+}
+
+func (c SenMLCodec) CodecName() string {
+	if c.CBOR {
+		return "SenML CBOR (RFC 8428)"
+	}
+	return "SenML JSON (RFC 8428)"
+}
+
+// SenMLCodec reads and writes SenML packs, see https://www.rfc-editor.org/rfc/rfc8428.
+type SenMLCodec struct {
+	CBOR bool
+}
@@ -0,0 +1,189 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+func parsePack(t *testing.T, str string) []Record {
+	var pack []Record
+	if err := json.Unmarshal([]byte(str), &pack); err != nil {
+		t.Fatal(err)
+	}
+	return pack
+}
+
+func TestResolve(t *testing.T) {
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	bt := time.Unix(1276020076, 1e6)
+
+	// RFC 8428 section 5.1.3, multiple measurements with base time and base unit
+	pack := parsePack(t, `[
+		{"bn":"urn:dev:ow:10e2073a0108006:","bt":1.276020076001e+09,"bu":"A","bver":5,"n":"voltage","u":"V","v":120.1},
+		{"n":"current","t":-5,"v":1.2},
+		{"n":"current","t":-4,"v":1.3},
+		{"n":"current","t":-3,"v":1.4},
+		{"n":"current","t":-2,"v":1.5},
+		{"n":"current","t":-1,"v":1.6},
+		{"n":"current","v":1.7}
+	]`)
+	series := resolve(pack, now)
+	if len(series) != 2 {
+		t.Fatalf("got %d series, want 2", len(series))
+	}
+	if s := series[0]; s.name != "voltage" || s.unit != "V" || len(s.values) != 1 || s.values[0].Value != 120.1 {
+		t.Errorf("voltage is %+v", s)
+	}
+	current := series[1]
+	if current.name != "current" || current.unit != "A" || len(current.values) != 6 {
+		t.Fatalf("current is %+v", current)
+	}
+	for i, v := range current.values {
+		want := bt.Add(time.Duration(i-5) * time.Second)
+		if d := v.Time.Sub(want); d > time.Millisecond || d < -time.Millisecond {
+			t.Errorf("current %d: time %v, want %v", i, v.Time, want)
+		}
+		if f := v.Value.(float64); f < 1.2+0.1*float64(i)-1e-9 || f > 1.2+0.1*float64(i)+1e-9 {
+			t.Errorf("current %d: value %v", i, f)
+		}
+	}
+
+	// base values and sums, relative times and other value types
+	pack = parsePack(t, `[
+		{"bn":"dev1/","bv":10,"bs":5,"n":"a","v":1,"t":-10},
+		{"n":"b","vs":"on"},
+		{"n":"c","vb":true},
+		{"n":"d","s":2},
+		{"n":"e","vd":"AQI"},
+		{"bn":"dev2/"},
+		{"n":"a","v":0}
+	]`)
+	series = resolve(pack, now)
+	values := map[string]interface{}{}
+	for _, s := range series {
+		values[s.name] = s.values[0].Value
+	}
+	want := map[string]interface{}{
+		"dev1/a": 11.0,
+		"dev1/b": "on",
+		"dev1/c": true,
+		"dev1/d": 7.0,
+		"dev1/e": []byte{1, 2},
+		"dev2/a": 10.0,
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+	if tm := series[0].values[0].Time; !tm.Equal(now.Add(-10 * time.Second)) {
+		t.Errorf("relative time: got %v", tm)
+	}
+}
+
+func TestSensorName(t *testing.T) {
+
+	tests := []struct {
+		baseName string
+		name     string
+		sensor   string
+	}{
+		{"urn:dev:ow:10e2073a01080063:", "temp", "temp"},
+		{"urn:dev:ow:10e2073a01080063:", "", "urn:dev:ow:10e2073a01080063:"},
+		{"room1/", "temp", "room1/temp"},
+		{"", "temp", "temp"},
+	}
+	for _, test := range tests {
+		if s := sensorName(test.baseName, test.name); s != test.sensor {
+			t.Errorf("%q %q: got %q, want %q", test.baseName, test.name, s, test.sensor)
+		}
+	}
+}
+
+func TestUnmarshalDevice(t *testing.T) {
+
+	// RFC 8428 section 5.1.2 (multiple datapoints) as JSON and as CBOR (section 6)
+	payloads := map[string][]byte{
+		"json": []byte(`[{"bn":"urn:dev:ow:10e2073a01080063:","n":"voltage","u":"V","v":120.1},{"n":"current","u":"A","v":1.2}]`),
+	}
+	payloads["cbor"], _ = hex.DecodeString("82a421781c75726e3a6465763a6f773a313065323037336130313038303036333a00" +
+		"67766f6c7461676501615602fb405e066666666666a3006763757272656e7401614102fb3ff3333333333333")
+
+	for format, payload := range payloads {
+		device := &edge.Device{
+			Sensors: []*edge.Sensor{{ID: "v1", Name: "voltage"}},
+		}
+		result := edge.TestCodec(SenMLCodec{CBOR: format == "cbor"}, device, nil, payload)
+		if len(result.Errors) != 0 {
+			t.Errorf("%s: errors: %v", format, result.Errors)
+			continue
+		}
+		if len(result.Sensors) != 2 {
+			t.Errorf("%s: got %d sensors, want 2", format, len(result.Sensors))
+			continue
+		}
+		if s := result.Sensors[0]; s.ID != "v1" || s.Value != 120.1 {
+			t.Errorf("%s: voltage is %+v", format, s)
+		}
+		if s := result.Sensors[1]; s.Name != "current" || s.Value != 1.2 || s.Meta["unit"] != "Ampere" || s.Meta["senmlUnit"] != "A" {
+			t.Errorf("%s: current is %+v", format, s)
+		}
+	}
+
+	result := edge.TestCodec(SenMLCodec{}, nil, nil, []byte(`{"n":"not a pack"}`))
+	if len(result.Errors) == 0 {
+		t.Errorf("no error for an invalid pack")
+	}
+
+	// records of actuators are not stored as sensors, but reported
+	device := &edge.Device{
+		Actuators: []*edge.Actuator{{ID: "a1", Name: "relay"}},
+	}
+	result = edge.TestCodec(SenMLCodec{}, device, nil, []byte(`[{"n":"relay","vb":true}]`))
+	if len(result.Sensors) != 0 {
+		t.Errorf("relay: got %d sensors, want none", len(result.Sensors))
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "relay") {
+		t.Errorf("relay: got warnings %q, want one for the dropped value", result.Warnings)
+	}
+}
+
+// deviceStore serves one device for MarshalDevice.
+type deviceStore struct {
+	edge.DeviceStore
+	device *edge.Device
+}
+
+func (s deviceStore) GetDevice(deviceID string) (*edge.Device, error) {
+	return s.device, nil
+}
+
+func TestMarshalDevice(t *testing.T) {
+
+	tm := time.Unix(1276020076, 0)
+	store := deviceStore{device: &edge.Device{
+		ID: "d1",
+		Sensors: []*edge.Sensor{
+			{Name: "humidity", Value: 55.0, Time: &tm, Meta: edge.Meta{"quantity": "RelativeHumidity", "unit": "Percent"}},
+			{Name: "empty"},
+		},
+		Actuators: []*edge.Actuator{
+			{Name: "relay", Value: true},
+			{Name: "color", Value: map[string]interface{}{"r": 1.0}},
+		},
+	}}
+	var buf bytes.Buffer
+	if err := (SenMLCodec{}).MarshalDevice(store, "d1", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"bn":"d1:","n":"humidity","u":"%RH","v":55,"t":1276020076},{"n":"relay","vb":true},{"n":"color","vs":"{\"r\":1}"}]`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package codec
+
+type def struct {
+	Kind     string
+	Quantity string
+	Unit     string
+}
+
+var temperature = def{
+	Kind:     "Thermometer",
+	Quantity: "Temperature",
+}
+
+var pressure = def{
+	Kind:     "PressureSensor",
+	Quantity: "AtmosphericPressure",
+}
+
+var voltage = def{
+	Kind:     "VoltageSensor",
+	Quantity: "Voltage",
+}
+
+var current = def{
+	Kind:     "CurrentSensor",
+	Quantity: "ElectricCurrent",
+}
+
+var power = def{
+	Kind:     "ElectricalSensor",
+	Quantity: "ActivePower",
+}
+
+// unitMapping maps SenML units (RFC 8428 section 12.1) to the ontology.
+var unitMapping = map[string]def{
+	"Cel":      withUnit(temperature, "DegreeCelsius"),
+	"K":        withUnit(temperature, "Kelvin"),
+	"%RH":      {Kind: "HumiditySensor", Quantity: "RelativeHumidity", Unit: "Percent"},
+	"%":        {Unit: "Percent"},
+	"%EL":      {Quantity: "BatteryLevel", Unit: "Percent"},
+	"Pa":       withUnit(pressure, "Pascal"),
+	"kPa":      withUnit(pressure, "KiloPascal"),
+	"bar":      withUnit(pressure, "Bar"),
+	"mbar":     withUnit(pressure, "Millibar"),
+	"V":        withUnit(voltage, "Volt"),
+	"mV":       withUnit(voltage, "Millivolt"),
+	"A":        withUnit(current, "Ampere"),
+	"mA":       withUnit(current, "Milliampere"),
+	"W":        withUnit(power, "Watt"),
+	"mW":       withUnit(power, "Milliwatt"),
+	"var":      {Kind: "ElectricalSensor", Quantity: "ReactivePower", Unit: "VoltAmpereReactive"},
+	"kWh":      {Kind: "EnergyMeter", Quantity: "Energy", Unit: "KiloWattHour"},
+	"J":        {Kind: "EnergyMeter", Quantity: "Energy", Unit: "Joule"},
+	"Ohm":      {Quantity: "ElectricalResistance", Unit: "Ohm"},
+	"C":        {Quantity: "ElectricCharge", Unit: "Coulomb"},
+	"F":        {Quantity: "Capacitance", Unit: "Farad"},
+	"S/m":      {Kind: "ConductivitySensor", Quantity: "Conductivity", Unit: "SiemensPerMetre"},
+	"lx":       {Kind: "LightSensor", Quantity: "Illuminance", Unit: "Lux"},
+	"lm":       {Quantity: "LuminousFlux", Unit: "Lumen"},
+	"cd":       {Quantity: "LuminousIntensity", Unit: "Candela"},
+	"W/m2":     {Kind: "SolarRadiationSensor", Quantity: "SolarRadiation", Unit: "WattPerSquareMetre"},
+	"ppm":      {Kind: "GaseousPollutantSensor", Quantity: "ChemicalAgentConcentration", Unit: "PartsPerMillion"},
+	"ppb":      {Kind: "GaseousPollutantSensor", Quantity: "ChemicalAgentConcentration", Unit: "PartsPerBillion"},
+	"ug/m3":    {Kind: "DustSensor", Quantity: "ChemicalAgentAtmosphericConcentrationDust", Unit: "MicrogramPerCubicMetre"},
+	"m":        {Kind: "DistanceSensor", Quantity: "Distance", Unit: "Metre"},
+	"mm":       {Kind: "DistanceSensor", Quantity: "Distance", Unit: "Millimetre"},
+	"km":       {Kind: "DistanceSensor", Quantity: "Distance", Unit: "Kilometre"},
+	"m/s":      {Kind: "SpeedSensor", Quantity: "Speed", Unit: "MetrePerSecond"},
+	"km/h":     {Kind: "SpeedSensor", Quantity: "Speed", Unit: "KilometrePerHour"},
+	"m/s2":     {Kind: "Accelerometer", Quantity: "Acceleration", Unit: "MetrePerSecondSquare"},
+	"mm/h":     {Kind: "RainFallSensor", Quantity: "Rainfall", Unit: "MillimetrePerHour"},
+	"Hz":       {Kind: "FrequencySensor", Quantity: "Frequency", Unit: "Hertz"},
+	"beat/min": {Kind: "HeartBeatSensor", Quantity: "HeartBeat", Unit: "BeatPerMinute"},
+	"dB":       {Kind: "SoundSensor", Quantity: "SoundPressureLevel", Unit: "Decibel"},
+	"dBm":      {Unit: "DecibelMilliwatt"},
+	"deg":      {Kind: "WindDirectionSensor", Quantity: "WindDirection", Unit: "DegreeAngle"},
+	"rad":      {Unit: "Radian"},
+	"kg":       {Kind: "WeightSensor", Quantity: "Weight", Unit: "Kilogram"},
+	"g":        {Kind: "WeightSensor", Quantity: "Weight", Unit: "Gram"},
+	"kg/m3":    {Unit: "KilogramPerCubicMetre"},
+	"l":        {Unit: "Litre"},
+	"T":        {Kind: "Magnetometer", Quantity: "MagneticFluxDensity", Unit: "Tesla"},
+	"Wb":       {Unit: "Weber"},
+	"S":        {Unit: "Siemens"},
+	"s":        {Unit: "SecondTime"},
+	"ms":       {Unit: "Millisecond"},
+	"min":      {Unit: "MinuteTime"},
+	"h":        {Unit: "Hour"},
+	"pH":       {Kind: "PHSensor", Quantity: "PH"},
+	"count":    {Kind: "Counter", Quantity: "Count"},
+}
+
+func withUnit(d def, unit string) def {
+	d.Unit = unit
+	return d
+}
+
+// senmlUnit returns the SenML unit for an ontology quantity and unit.
+func senmlUnit(quantity string, unit string) string {
+	if unit == "" {
+		return ""
+	}
+	if unit == "Percent" {
+		switch quantity {
+		case "RelativeHumidity":
+			return "%RH"
+		case "BatteryLevel":
+			return "%EL"
+		}
+		return "%"
+	}
+	for u, d := range unitMapping {
+		if d.Unit == unit {
+			return u
+		}
+	}
+	return ""
+}
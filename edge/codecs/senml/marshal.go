@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// MarshalDevice writes the current values of all sensors and actuators as SenML pack.
+// The base name is the device ID, followed by ':'.
+func (c SenMLCodec) MarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	pack := []Record{}
+	for _, sensor := range device.Sensors {
+		quantity, unit := sensor.Quantity.String(), sensor.Unit.String()
+		if quantity == "" {
+			quantity, _ = sensor.Meta["quantity"].(string)
+		}
+		if unit == "" {
+			unit, _ = sensor.Meta["unit"].(string)
+		}
+		if r, ok := record(sensor.Name, sensor.Value, sensor.Time, quantity, unit); ok {
+			pack = append(pack, r)
+		}
+	}
+	for _, actuator := range device.Actuators {
+		quantity, _ := actuator.Meta["quantity"].(string)
+		unit, _ := actuator.Meta["unit"].(string)
+		if r, ok := record(actuator.Name, actuator.Value, actuator.Time, quantity, unit); ok {
+			pack = append(pack, r)
+		}
+	}
+	if len(pack) != 0 {
+		pack[0].BaseName = device.ID + ":"
+	}
+
+	if c.CBOR {
+		return cbor.NewEncoder(w).Encode(pack)
+	}
+	return json.NewEncoder(w).Encode(pack)
+}
+
+func record(name string, value interface{}, t *time.Time, quantity string, unit string) (Record, bool) {
+	r := Record{
+		Name: name,
+		Unit: senmlUnit(quantity, unit),
+	}
+	if t != nil {
+		r.Time = float64(t.UnixNano()) / 1e9
+	}
+	switch v := value.(type) {
+	case nil:
+		return r, false
+	case float64:
+		r.Value = &v
+	case float32:
+		f := float64(v)
+		r.Value = &f
+	case int:
+		f := float64(v)
+		r.Value = &f
+	case int32:
+		f := float64(v)
+		r.Value = &f
+	case int64:
+		f := float64(v)
+		r.Value = &f
+	case bool:
+		r.BoolValue = &v
+	case string:
+		r.StringValue = &v
+	case []byte:
+		r.DataValue = v
+	default:
+		// SenML has no objects, so structured values are written as JSON string
+		data, err := json.Marshal(v)
+		if err != nil {
+			return r, false
+		}
+		str := string(data)
+		r.StringValue = &str
+	}
+	return r, true
+}
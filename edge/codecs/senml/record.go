@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Record is one SenML record. The CBOR labels are defined in RFC 8428 section 6.
+type Record struct {
+	BaseVersion int      `json:"bver,omitempty" cbor:"-1,keyasint,omitempty"`
+	BaseName    string   `json:"bn,omitempty" cbor:"-2,keyasint,omitempty"`
+	BaseTime    float64  `json:"bt,omitempty" cbor:"-3,keyasint,omitempty"`
+	BaseUnit    string   `json:"bu,omitempty" cbor:"-4,keyasint,omitempty"`
+	BaseValue   *float64 `json:"bv,omitempty" cbor:"-5,keyasint,omitempty"`
+	BaseSum     *float64 `json:"bs,omitempty" cbor:"-6,keyasint,omitempty"`
+
+	Name        string   `json:"n,omitempty" cbor:"0,keyasint,omitempty"`
+	Unit        string   `json:"u,omitempty" cbor:"1,keyasint,omitempty"`
+	Value       *float64 `json:"v,omitempty" cbor:"2,keyasint,omitempty"`
+	StringValue *string  `json:"vs,omitempty" cbor:"3,keyasint,omitempty"`
+	BoolValue   *bool    `json:"vb,omitempty" cbor:"4,keyasint,omitempty"`
+	Sum         *float64 `json:"s,omitempty" cbor:"5,keyasint,omitempty"`
+	Time        float64  `json:"t,omitempty" cbor:"6,keyasint,omitempty"`
+	UpdateTime  float64  `json:"ut,omitempty" cbor:"7,keyasint,omitempty"`
+	DataValue   Data     `json:"vd,omitempty" cbor:"8,keyasint,omitempty"`
+}
+
+// Data is a byte string, base64url encoded in JSON and a byte string in CBOR.
+type Data []byte
+
+func (d Data) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(d))
+}
+
+func (d *Data) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	*d = b
+	return nil
+}
@@ -0,0 +1,172 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// relativeTimeLimit is 2**28: smaller times are relative to now (RFC 8428 section 4.5.3).
+const relativeTimeLimit = 1 << 28
+
+type series struct {
+	name   string
+	unit   string
+	values []edge.Value
+}
+
+func (c SenMLCodec) UnmarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
+
+	device, err := store.GetDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	var pack []Record
+	if c.CBOR {
+		err = cbor.NewDecoder(r).Decode(&pack)
+	} else {
+		err = json.NewDecoder(r).Decode(&pack)
+	}
+	if err != nil {
+		return edge.NewErrorf(400, "Err Codec SenML: %v", err)
+	}
+
+	for _, s := range resolve(pack, time.Now()) {
+		if err := postSeries(store, device, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve applies the base fields to the records and groups the values by name.
+func resolve(pack []Record, now time.Time) []*series {
+
+	var baseName, baseUnit string
+	var baseTime, baseValue, baseSum float64
+
+	var result []*series
+	byName := map[string]*series{}
+
+	for _, r := range pack {
+		if r.BaseName != "" {
+			baseName = r.BaseName
+		}
+		if r.BaseTime != 0 {
+			baseTime = r.BaseTime
+		}
+		if r.BaseUnit != "" {
+			baseUnit = r.BaseUnit
+		}
+		if r.BaseValue != nil {
+			baseValue = *r.BaseValue
+		}
+		if r.BaseSum != nil {
+			baseSum = *r.BaseSum
+		}
+
+		var value interface{}
+		switch {
+		case r.Value != nil:
+			value = baseValue + *r.Value
+		case r.StringValue != nil:
+			value = *r.StringValue
+		case r.BoolValue != nil:
+			value = *r.BoolValue
+		case r.DataValue != nil:
+			value = []byte(r.DataValue)
+		case r.Sum != nil:
+			value = baseSum + *r.Sum
+		case r.BaseValue != nil:
+			value = baseValue
+		default:
+			continue // a record with base fields only
+		}
+
+		name := sensorName(baseName, r.Name)
+		if name == "" {
+			continue
+		}
+
+		t := baseTime + r.Time
+		var tm time.Time
+		if t < relativeTimeLimit {
+			tm = now.Add(time.Duration(t * float64(time.Second)))
+		} else {
+			sec, frac := math.Modf(t)
+			tm = time.Unix(int64(sec), int64(frac*1e9))
+		}
+
+		unit := r.Unit
+		if unit == "" {
+			unit = baseUnit
+		}
+
+		s := byName[name]
+		if s == nil {
+			s = &series{name: name, unit: unit}
+			byName[name] = s
+			result = append(result, s)
+		}
+		s.values = append(s.values, edge.NewValue(value, tm))
+	}
+
+	for _, s := range result {
+		sort.SliceStable(s.values, func(i, j int) bool {
+			return s.values[i].Time.Before(s.values[j].Time)
+		})
+	}
+	return result
+}
+
+// sensorName concatenates the base name and the name.
+// Base names ending with ':' are device identifiers like "urn:dev:mac:0024befffe804ff1:" and are omitted.
+func sensorName(baseName string, name string) string {
+	if strings.HasSuffix(baseName, ":") && name != "" {
+		return name
+	}
+	return baseName + name
+}
+
+func postSeries(store edge.DeviceStore, device *edge.Device, s *series) error {
+	for _, actuator := range device.Actuators {
+		if actuator.Name == s.name {
+			// uplinks do not change actuators, so the values are reported and dropped
+			warning := fmt.Sprintf("record %q matches actuator %q, dropped %d values", s.name, actuator.ID, len(s.values))
+			store.Report(device.ID, []string{warning}, nil, nil)
+			return nil
+		}
+	}
+	for _, sensor := range device.Sensors {
+		if sensor.Name == s.name {
+			_, err := store.PostSensorValues(device.ID, sensor.ID, s.values)
+			return err
+		}
+	}
+
+	d := unitMapping[s.unit]
+	sensor := &edge.Sensor{
+		Name: s.name,
+		Meta: edge.Meta{
+			"kind":      d.Kind,
+			"quantity":  d.Quantity,
+			"unit":      d.Unit,
+			"senmlUnit": s.unit,
+			"createdBy": "codec:senml",
+		},
+	}
+	if err := store.PostSensor(device.ID, sensor); err != nil {
+		return err
+	}
+	_, err := store.PostSensorValues(device.ID, sensor.ID, s.values)
+	return err
+}
@@ -0,0 +1,324 @@
+// Package ttn imports codecs from the TTN Device Repository,
+// see https://github.com/TheThingsNetwork/lorawan-devices.
+package ttn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	executor "github.com/Waziup/wazigate-edge/edge/codecs/javascript"
+	"gopkg.in/yaml.v3"
+)
+
+// Result lists the imported codecs.
+type Result struct {
+	Codecs []*ImportedCodec `json:"codecs"`
+}
+
+// ImportedCodec is a codec of the repository and the result of its self-tests.
+// Codecs that fail their self-tests are not saved, unless the import is forced.
+type ImportedCodec struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Tests    []*TestResult `json:"tests"`
+	Passed   bool          `json:"passed"`
+	Imported bool          `json:"imported"`
+}
+
+// TestResult is the result of one example of the codec definition.
+type TestResult struct {
+	Description string      `json:"description"`
+	Direction   string      `json:"direction"`
+	Passed      bool        `json:"passed"`
+	Error       string      `json:"error,omitempty"`
+	Expected    interface{} `json:"expected"`
+	Actual      interface{} `json:"actual"`
+}
+
+type device struct {
+	Name             string `yaml:"name"`
+	FirmwareVersions []struct {
+		Profiles map[string]struct {
+			Codec string `yaml:"codec"`
+		} `yaml:"profiles"`
+	} `yaml:"firmwareVersions"`
+}
+
+type vendorIndex struct {
+	EndDevices []string `yaml:"endDevices"`
+}
+
+type codecDefinition struct {
+	UplinkDecoder   *script `yaml:"uplinkDecoder"`
+	DownlinkEncoder *script `yaml:"downlinkEncoder"`
+}
+
+type script struct {
+	FileName string    `yaml:"fileName"`
+	Examples []example `yaml:"examples"`
+}
+
+type example struct {
+	Description string `yaml:"description"`
+	Input       struct {
+		FPort int         `yaml:"fPort"`
+		Bytes []int       `yaml:"bytes"`
+		Data  interface{} `yaml:"data"`
+	} `yaml:"input"`
+	Output struct {
+		FPort  int         `yaml:"fPort"`
+		Bytes  []int       `yaml:"bytes"`
+		Data   interface{} `yaml:"data"`
+		Errors []string    `yaml:"errors"`
+	} `yaml:"output"`
+}
+
+// postCodec saves a codec that passed its self-tests.
+var postCodec = edge.PostCodec
+
+// Root returns the directory of the repository that contains the 'vendor' folder.
+// This is either the root itself or a folder one level below,
+// like 'lorawan-devices-master' in a zip downloaded from GitHub.
+func Root(fsys fs.FS) (fs.FS, error) {
+	if info, err := fs.Stat(fsys, "vendor"); err == nil && info.IsDir() {
+		return fsys, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if info, err := fs.Stat(fsys, entry.Name()+"/vendor"); err == nil && info.IsDir() {
+			return fs.Sub(fsys, entry.Name())
+		}
+	}
+	return nil, fmt.Errorf("no 'vendor' folder found")
+}
+
+// Import runs the examples of the codecs of that device of the vendor as self-tests
+// and creates the codecs that passed them (or all codecs if force is set).
+// If the device is empty, the codecs of all devices of the vendor are imported.
+func Import(fsys fs.FS, vendor string, deviceID string, author string, force bool) (*Result, error) {
+
+	fsys, err := Root(fsys)
+	if err != nil {
+		return nil, edge.NewErrorf(400, "Err importing: %v", err)
+	}
+	if vendor == "" || strings.ContainsAny(vendor, "/\\.") || strings.ContainsAny(deviceID, "/\\.") {
+		return nil, edge.NewError(400, "Err importing: invalid vendor or device")
+	}
+	dir := "vendor/" + vendor
+
+	devices := []string{deviceID}
+	if deviceID == "" {
+		var index vendorIndex
+		if err := readYAML(fsys, dir+"/index.yaml", &index); err != nil {
+			return nil, edge.NewErrorf(404, "Err importing: %v", err)
+		}
+		devices = index.EndDevices
+	}
+
+	result := &Result{
+		Codecs: []*ImportedCodec{},
+	}
+	imported := map[string]bool{}
+	for _, deviceID := range devices {
+		var dev device
+		if err := readYAML(fsys, dir+"/"+deviceID+".yaml", &dev); err != nil {
+			return nil, edge.NewErrorf(404, "Err importing: %v", err)
+		}
+		for _, firmware := range dev.FirmwareVersions {
+			for _, profile := range firmware.Profiles {
+				if profile.Codec == "" || imported[profile.Codec] {
+					continue
+				}
+				imported[profile.Codec] = true
+				codec, err := importCodec(fsys, dir, vendor, profile.Codec, author, force)
+				if err != nil {
+					return nil, err
+				}
+				result.Codecs = append(result.Codecs, codec)
+			}
+		}
+	}
+	return result, nil
+}
+
+func importCodec(fsys fs.FS, dir string, vendor string, codecID string, author string, force bool) (*ImportedCodec, error) {
+
+	var def codecDefinition
+	if err := readYAML(fsys, dir+"/"+codecID+".yaml", &def); err != nil {
+		return nil, edge.NewErrorf(404, "Err importing codec %q: %v", codecID, err)
+	}
+
+	var files []string
+	var sources []string
+	for _, s := range []*script{def.UplinkDecoder, def.DownlinkEncoder} {
+		if s == nil || s.FileName == "" || contains(files, s.FileName) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, s.FileName))
+		if err != nil {
+			return nil, edge.NewErrorf(404, "Err importing codec %q: %v", codecID, err)
+		}
+		files = append(files, s.FileName)
+		sources = append(sources, string(data))
+	}
+	if len(sources) == 0 {
+		return nil, edge.NewErrorf(400, "Err importing codec %q: no uplink decoder or downlink encoder", codecID)
+	}
+
+	codec := &edge.ScriptCodec{
+		ID:        "ttn-" + vendor + "-" + codecID,
+		Name:      vendor + " " + codecID,
+		Mime:      "application/javascript",
+		ServeMime: "application/x-ttn-" + vendor + "-" + codecID,
+		Script:    strings.Join(sources, "\n"),
+		Author:    author,
+	}
+	imported := &ImportedCodec{
+		ID:     codec.ID,
+		Name:   codec.Name,
+		Tests:  []*TestResult{},
+		Passed: true,
+	}
+	if def.UplinkDecoder != nil {
+		for _, ex := range def.UplinkDecoder.Examples {
+			imported.Tests = append(imported.Tests, testUplink(codec, &ex))
+		}
+	}
+	if def.DownlinkEncoder != nil {
+		for _, ex := range def.DownlinkEncoder.Examples {
+			imported.Tests = append(imported.Tests, testDownlink(codec, &ex))
+		}
+	}
+	for _, test := range imported.Tests {
+		if !test.Passed {
+			imported.Passed = false
+		}
+	}
+	if !imported.Passed && !force {
+		return imported, nil
+	}
+	if err := postCodec(codec); err != nil {
+		return nil, err
+	}
+	imported.Imported = true
+	return imported, nil
+}
+
+func testUplink(codec *edge.ScriptCodec, ex *example) *TestResult {
+	result := &TestResult{
+		Description: ex.Description,
+		Direction:   "uplink",
+		Expected:    normalize(ex.Output.Data),
+	}
+	payload := make([]byte, len(ex.Input.Bytes))
+	for i, b := range ex.Input.Bytes {
+		payload[i] = byte(b)
+	}
+	output, _, err := executor.Decode(codec, payload, ex.Input.FPort)
+	if err != nil {
+		result.Error = err.Error()
+		result.Passed = len(ex.Output.Errors) != 0
+		return result
+	}
+	result.Actual = normalize(output.Data)
+	if len(ex.Output.Errors) != 0 {
+		result.Passed = len(output.Errors) != 0
+		return result
+	}
+	result.Passed = reflect.DeepEqual(result.Expected, result.Actual)
+	return result
+}
+
+func testDownlink(codec *edge.ScriptCodec, ex *example) *TestResult {
+	result := &TestResult{
+		Description: ex.Description,
+		Direction:   "downlink",
+		Expected:    ex.Output.Bytes,
+	}
+	var data map[string]interface{}
+	if m, ok := normalize(ex.Input.Data).(map[string]interface{}); ok {
+		data = m
+	}
+	fPort := ex.Input.FPort
+	if fPort == 0 {
+		fPort = executor.DefaultFPort
+	}
+	output, _, err := executor.Encode(codec, data, fPort)
+	if err != nil {
+		result.Error = err.Error()
+		result.Passed = len(ex.Output.Errors) != 0
+		return result
+	}
+	result.Actual = output.Bytes
+	if len(ex.Output.Errors) != 0 {
+		result.Passed = len(output.Errors) != 0
+		return result
+	}
+	result.Passed = reflect.DeepEqual(normalize(ex.Output.Bytes), normalize(output.Bytes)) &&
+		(ex.Output.FPort == 0 || ex.Output.FPort == output.FPort)
+	return result
+}
+
+func readYAML(fsys fs.FS, name string, v interface{}) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// normalize converts YAML values to what they would look like in JSON,
+// so that they can be compared with the script output.
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(jsonCompatible(v))
+	if err != nil {
+		return v
+	}
+	var n interface{}
+	json.Unmarshal(data, &n)
+	return n
+}
+
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[key] = jsonCompatible(value)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, value := range t {
+			l[i] = jsonCompatible(value)
+		}
+		return l
+	}
+	return v
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package ttn
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/Waziup/wazigate-edge/edge"
+)
+
+// repository is a downloaded copy of the device repository with one vendor,
+// one device and two codecs: one passes its examples, one does not.
+var repository = fstest.MapFS{
+	"lorawan-devices-master/vendor/acme/index.yaml": {Data: []byte(`
+endDevices:
+  - sensor
+`)},
+	"lorawan-devices-master/vendor/acme/sensor.yaml": {Data: []byte(`
+name: Sensor
+firmwareVersions:
+  - version: "1.0"
+    profiles:
+      EU863-870:
+        codec: sensor-codec
+  - version: "2.0"
+    profiles:
+      EU863-870:
+        codec: sensor-codec
+      US902-928:
+        codec: broken-codec
+`)},
+	"lorawan-devices-master/vendor/acme/sensor-codec.yaml": {Data: []byte(`
+uplinkDecoder:
+  fileName: sensor.js
+  examples:
+    - description: Temperature
+      input:
+        fPort: 1
+        bytes: [21, 1]
+      output:
+        data:
+          temperature: 21
+          on: true
+    - description: Invalid port
+      input:
+        fPort: 2
+        bytes: [21]
+      output:
+        errors:
+          - unknown port
+downlinkEncoder:
+  fileName: sensor.js
+  examples:
+    - description: Switch on
+      input:
+        data:
+          on: true
+      output:
+        fPort: 3
+        bytes: [1]
+`)},
+	"lorawan-devices-master/vendor/acme/sensor.js": {Data: []byte(`
+function decodeUplink(input) {
+  if (input.fPort != 1) {
+    return {errors: ["unknown port"]};
+  }
+  return {data: {temperature: input.bytes[0], on: input.bytes[1] == 1}};
+}
+function encodeDownlink(input) {
+  return {bytes: [input.data.on ? 1 : 0], fPort: 3};
+}
+`)},
+	"lorawan-devices-master/vendor/acme/broken-codec.yaml": {Data: []byte(`
+uplinkDecoder:
+  fileName: broken.js
+  examples:
+    - description: Temperature
+      input:
+        fPort: 1
+        bytes: [21]
+      output:
+        data:
+          temperature: 21
+`)},
+	"lorawan-devices-master/vendor/acme/broken.js": {Data: []byte(`
+function decodeUplink(input) {
+  return {data: {temperature: input.bytes[0] / 10}};
+}
+`)},
+}
+
+// savedCodecs replaces postCodec while the test runs and collects the saved codecs.
+func savedCodecs(t *testing.T) map[string]*edge.ScriptCodec {
+	saved := map[string]*edge.ScriptCodec{}
+	postCodec = func(codec *edge.ScriptCodec) error {
+		saved[codec.ID] = codec
+		return nil
+	}
+	t.Cleanup(func() {
+		postCodec = edge.PostCodec
+	})
+	return saved
+}
+
+func errorCode(err error) int {
+	if e, ok := err.(edge.CodeError); ok {
+		return e.Code
+	}
+	return 0
+}
+
+func TestImport(t *testing.T) {
+
+	saved := savedCodecs(t)
+	result, err := Import(repository, "acme", "", "admin", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Codecs) != 2 {
+		t.Fatalf("got %d codecs, want 2 (each codec once)", len(result.Codecs))
+	}
+	codecs := map[string]*ImportedCodec{}
+	for _, codec := range result.Codecs {
+		codecs[codec.ID] = codec
+	}
+
+	sensor := codecs["ttn-acme-sensor-codec"]
+	if sensor == nil || !sensor.Passed || !sensor.Imported || len(sensor.Tests) != 3 {
+		t.Fatalf("sensor codec: %+v, want 3 passed tests and imported", sensor)
+	}
+	for _, test := range sensor.Tests {
+		if !test.Passed {
+			t.Errorf("%s %q failed: %s, got %v, want %v", test.Direction, test.Description, test.Error, test.Actual, test.Expected)
+		}
+	}
+
+	broken := codecs["ttn-acme-broken-codec"]
+	if broken == nil || broken.Passed || broken.Imported {
+		t.Fatalf("broken codec: %+v, want failed and not imported", broken)
+	}
+
+	if len(saved) != 1 {
+		t.Fatalf("saved %d codecs, want 1", len(saved))
+	}
+	codec := saved["ttn-acme-sensor-codec"]
+	if codec == nil {
+		t.Fatalf("the sensor codec was not saved")
+	}
+	if codec.Name != "acme sensor-codec" || codec.Author != "admin" || codec.ServeMime != "application/x-ttn-acme-sensor-codec" {
+		t.Errorf("saved codec %q by %q (%s)", codec.Name, codec.Author, codec.ServeMime)
+	}
+}
+
+func TestImportForce(t *testing.T) {
+
+	saved := savedCodecs(t)
+	result, err := Import(repository, "acme", "sensor", "admin", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Codecs) != 2 || len(saved) != 2 {
+		t.Fatalf("got %d codecs and saved %d, want 2 and 2", len(result.Codecs), len(saved))
+	}
+	for _, codec := range result.Codecs {
+		if !codec.Imported {
+			t.Errorf("%s was not imported", codec.ID)
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+
+	savedCodecs(t)
+	tests := []struct {
+		vendor string
+		device string
+		code   int
+	}{
+		{"", "", 400},
+		{"../acme", "", 400},
+		{"acme", "../sensor", 400},
+		{"other", "", 404},
+		{"acme", "missing", 404},
+	}
+
+	for _, test := range tests {
+		_, err := Import(repository, test.vendor, test.device, "admin", false)
+		if code := errorCode(err); err == nil || code != test.code {
+			t.Errorf("%q %q: got %v (%d), want code %d", test.vendor, test.device, err, code, test.code)
+		}
+	}
+
+	if _, err := Import(fstest.MapFS{"README.md": {}}, "acme", "", "admin", false); errorCode(err) != 400 {
+		t.Errorf("no vendor folder: got %v, want code 400", err)
+	}
+}
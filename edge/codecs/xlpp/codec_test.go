@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Waziup/wazigate-edge/edge"
+	"github.com/waziup/xlpp"
+)
+
+// deviceStore serves one device to MarshalDevice.
+type deviceStore struct {
+	edge.DeviceStore
+	device *edge.Device
+}
+
+func (s deviceStore) GetDevice(string) (*edge.Device, error) {
+	return s.device, nil
+}
+
+func TestChannelOverrides(t *testing.T) {
+
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	temperature := xlpp.Temperature(21.5)
+	luminosity := xlpp.Luminosity(300)
+	integer := xlpp.Integer(7)
+	w.Add(1, &temperature)
+	w.Add(2, &luminosity)
+	w.Add(3, &integer)
+	w.Add(0, &xlpp.ActuatorsWithChannel{{Channel: 5, Type: xlpp.TypeAnalogOutput}})
+
+	device := &edge.Device{
+		Meta: edge.Meta{
+			"xlppChannels": map[string]interface{}{
+				"1": map[string]interface{}{"name": "Soil Temperature", "kind": "SoilThermometer"},
+				"2": map[string]interface{}{"ignore": true},
+				"3": map[string]interface{}{"scale": 10.0, "offset": 1.0},
+				"5": map[string]interface{}{"id": "valve", "quantity": "Voltage", "unit": "Volt"},
+			},
+		},
+	}
+	result := edge.TestCodec(XLPPCodec{}, device, nil, buf.Bytes())
+
+	if len(result.Errors) != 0 {
+		t.Fatalf("errors: %v", result.Errors)
+	}
+	if len(result.Sensors) != 2 {
+		t.Fatalf("got %d sensors, want 2 (channel 2 is ignored)", len(result.Sensors))
+	}
+	soil := result.Sensors[0]
+	if soil.Name != "Soil Temperature" || soil.Meta["kind"] != "SoilThermometer" || soil.Meta["quantity"] != "Temperature" {
+		t.Errorf("channel 1 is %q %v, want the name and kind of the override", soil.Name, soil.Meta)
+	}
+	if value, ok := result.Sensors[1].Value.(int64); !ok || value != 71 {
+		t.Errorf("channel 3 is %#v, want int64(71)", result.Sensors[1].Value)
+	}
+
+	if len(result.Actuators) != 1 {
+		t.Fatalf("got %d actuators, want 1", len(result.Actuators))
+	}
+	valve := result.Actuators[0]
+	if valve.ID != "valve" || valve.Meta["quantity"] != "Voltage" || xlppType(valve.Meta) != xlpp.TypeAnalogOutput {
+		t.Errorf("channel 5 is %q %v, want the overridden id and quantity and the XLPP type", valve.ID, valve.Meta)
+	}
+}
+
+func TestScale(t *testing.T) {
+
+	tests := []struct {
+		override ChannelOverride
+		value    interface{}
+		scaled   interface{}
+		unscaled interface{}
+	}{
+		{ChannelOverride{}, 5, 5, 5},
+		{ChannelOverride{Scale: 0.1}, 5.0, 0.5, 5.0},
+		{ChannelOverride{Scale: 0.5}, 5, 2.5, 5.0},
+		{ChannelOverride{Scale: 2, Offset: -1}, 5, int64(9), 5.0},
+		{ChannelOverride{Offset: 1.5}, 5, 6.5, 5.0},
+		{ChannelOverride{Scale: 2}, "five", "five", "five"},
+	}
+
+	for _, test := range tests {
+		scaled := test.override.scale(test.value)
+		if scaled != test.scaled {
+			t.Errorf("%+v: scale(%#v) = %#v, want %#v", test.override, test.value, scaled, test.scaled)
+		}
+		if unscaled := test.override.unscale(test.scaled); unscaled != test.unscaled {
+			t.Errorf("%+v: unscale(%#v) = %#v, want %#v", test.override, test.scaled, unscaled, test.unscaled)
+		}
+	}
+}
+
+func TestMarshalOverridden(t *testing.T) {
+
+	device := &edge.Device{
+		ID: "d1",
+		Meta: edge.Meta{
+			"xlppChannels": map[string]interface{}{
+				"5": map[string]interface{}{"quantity": "Voltage", "unit": "Volt", "scale": 2.0, "offset": 2.0},
+			},
+		},
+		Actuators: []*edge.Actuator{{
+			ID:    "valve",
+			Value: 12.0,
+			Meta: edge.Meta{
+				"quantity": "Voltage",
+				"unit":     "Volt",
+				"xlppChan": 5.0,
+				"xlppType": float64(xlpp.TypeAnalogOutput),
+			},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := (XLPPCodec{}).MarshalDevice(deviceStore{device: device}, device.ID, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	channel, value, err := xlpp.NewReader(&buf).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := value.(*xlpp.AnalogOutput); channel != 5 || !ok || *v != 5 {
+		t.Errorf("got channel %d = %#v, want 5 = AnalogOutput(5)", channel, value)
+	}
+}
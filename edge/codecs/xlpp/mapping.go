@@ -1,6 +1,13 @@
 package codec
 
 import (
+	"encoding/json"
+	"log"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/Waziup/wazigate-edge/edge"
 	"github.com/waziup/xlpp"
 )
 
@@ -272,3 +279,124 @@ func typeFromDef(quantity string, unit string) xlpp.Type {
 	}
 	return 255
 }
+
+// ChannelOverride changes how an XLPP channel becomes a sensor or actuator.
+// Overrides are set in the device meta "xlppChannels", keyed by channel:
+//
+//	"xlppChannels": {
+//	  "3": {"name": "Soil Temperature 30cm", "kind": "SoilThermometer"},
+//	  "5": {"ignore": true},
+//	  "7": {"scale": 0.1}
+//	}
+//
+// Devices created from a template get the overrides of the template meta.
+type ChannelOverride struct {
+	ID       string  `json:"id,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Kind     string  `json:"kind,omitempty"`
+	Quantity string  `json:"quantity,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+	Ignore   bool    `json:"ignore,omitempty"`
+	Scale    float64 `json:"scale,omitempty"`
+	Offset   float64 `json:"offset,omitempty"`
+}
+
+func channelOverrides(device *edge.Device) map[int]ChannelOverride {
+	m := device.Meta["xlppChannels"]
+	if m == nil {
+		return nil
+	}
+	var overrides map[string]ChannelOverride
+	data, _ := json.Marshal(m)
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		log.Printf("[ERR  ] Codec XLPP: Device %s: Meta 'xlppChannels': %v", device.ID, err)
+		return nil
+	}
+	result := make(map[int]ChannelOverride, len(overrides))
+	for key, o := range overrides {
+		channel, err := strconv.Atoi(key)
+		if err != nil {
+			log.Printf("[ERR  ] Codec XLPP: Device %s: Meta 'xlppChannels': invalid channel %q", device.ID, key)
+			continue
+		}
+		result[channel] = o
+	}
+	return result
+}
+
+// apply overrides the definition.
+func (o ChannelOverride) apply(d def) def {
+	if o.Kind != "" {
+		d.Kind = o.Kind
+	}
+	if o.Quantity != "" {
+		d.Quantity = o.Quantity
+	}
+	if o.Unit != "" {
+		d.Unit = o.Unit
+	}
+	return d
+}
+
+// number returns the value as float64 and if it is an integer type.
+// ok is false for values that are not numbers.
+func number(value interface{}) (f float64, integer bool, ok bool) {
+	if value == nil {
+		return 0, false, false
+	}
+	v := reflect.Indirect(reflect.ValueOf(value))
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true, true
+	}
+	return 0, false, false
+}
+
+// integral tells if scale and offset turn integers into integers.
+func (o ChannelOverride) integral() bool {
+	return o.Scale == math.Trunc(o.Scale) && o.Offset == math.Trunc(o.Offset)
+}
+
+// scale applies scale and offset to numeric uplink values. Other values are not changed.
+// Integer values stay integers if scale and offset are integers.
+func (o ChannelOverride) scale(value interface{}) interface{} {
+	if o.Scale == 0 && o.Offset == 0 {
+		return value
+	}
+	f, integer, ok := number(value)
+	if !ok {
+		return value
+	}
+	if o.Scale != 0 {
+		f *= o.Scale
+	}
+	f += o.Offset
+	if integer && o.integral() {
+		return int64(f)
+	}
+	return f
+}
+
+// unscale reverts scale and offset for downlink values: (value - offset) / scale.
+// Results that are integers up to rounding errors are rounded.
+func (o ChannelOverride) unscale(value interface{}) interface{} {
+	if o.Scale == 0 && o.Offset == 0 {
+		return value
+	}
+	f, _, ok := number(value)
+	if !ok {
+		return value
+	}
+	f -= o.Offset
+	if o.Scale != 0 {
+		f /= o.Scale
+	}
+	if r := math.Round(f); math.Abs(f-r) < 1e-9 {
+		return r
+	}
+	return f
+}
@@ -10,13 +10,15 @@ import (
 	"github.com/waziup/xlpp"
 )
 
-func (XLPPCodec) MarshalDevice(deviceID string, headers http.Header, w io.Writer) error {
+func (XLPPCodec) MarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, w io.Writer) error {
 
-	device, err := edge.GetDevice(deviceID)
+	device, err := store.GetDevice(deviceID)
 	if err != nil {
 		return err
 	}
 
+	overrides := channelOverrides(device)
+
 	writer := xlpp.NewWriter(w)
 	for _, actuator := range device.Actuators {
 		channel := xlppChan(actuator.Meta)
@@ -26,13 +28,17 @@ func (XLPPCodec) MarshalDevice(deviceID string, headers http.Header, w io.Writer
 		}
 		quantity, _ := actuator.Meta["quantity"].(string)
 		unit, _ := actuator.Meta["unit"].(string)
-		t := typeFromDef(quantity, unit)
+		// actuators created by this codec keep their type, even if the quantity or unit was overridden
+		t := xlppType(actuator.Meta)
+		if xlpp.Registry[t] == nil {
+			t = typeFromDef(quantity, unit)
+		}
 		if t == 255 {
 			log.Printf("Err Codec XLPP: Actuator %s/%s: No type for (quantity:%q, unit:%q)", device.ID, actuator.ID, quantity, unit)
 			continue
 		}
 		v := xlpp.Registry[t]()
-		d, _ := json.Marshal(actuator.Value)
+		d, _ := json.Marshal(overrides[channel].unscale(actuator.Value))
 		err := json.Unmarshal(d, v)
 		if err != nil {
 			log.Printf("Err Codec XLPP: Actuator %s/%s: Can not assign value (quantity:%q, unit:%q) %q to %s", device.ID, actuator.ID, quantity, unit, actuator.Value, typeName(v))
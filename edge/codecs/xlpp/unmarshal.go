@@ -11,14 +11,15 @@ import (
 	"github.com/waziup/xlpp"
 )
 
-func (XLPPCodec) UnmarshalDevice(deviceID string, headers http.Header, r io.Reader) error {
+func (XLPPCodec) UnmarshalDevice(store edge.DeviceStore, deviceID string, headers http.Header, r io.Reader) error {
 
-	device, err := edge.GetDevice(deviceID)
+	device, err := store.GetDevice(deviceID)
 	if err != nil {
 		return err
 	}
 
 	t := time.Now()
+	overrides := channelOverrides(device)
 
 	reader := xlpp.NewReader(r)
 	for {
@@ -31,57 +32,75 @@ func (XLPPCodec) UnmarshalDevice(deviceID string, headers http.Header, r io.Read
 			t = t.Add(-time.Duration(*v))
 		case *xlpp.Actuators:
 			for i, t := range *v {
-				if err := createActuator(device, i, t); err != nil {
+				if err := createActuator(store, device, i, t, overrides[i]); err != nil {
 					return err
 				}
 			}
 		case *xlpp.ActuatorsWithChannel:
 			for _, a := range *v {
-				if err := createActuator(device, a.Channel, a.Type); err != nil {
+				if err := createActuator(store, device, a.Channel, a.Type, overrides[a.Channel]); err != nil {
 					return err
 				}
 			}
 		default:
-			if err := createSensor(device, channel, v, t); err != nil {
+			if err := createSensor(store, device, channel, v, t, overrides[channel]); err != nil {
 				return err
 			}
 		}
 	}
 }
 
-func createActuator(device *edge.Device, channel int, t xlpp.Type) error {
+func createActuator(store edge.DeviceStore, device *edge.Device, channel int, t xlpp.Type, o ChannelOverride) error {
+	if o.Ignore {
+		return nil
+	}
 	for _, actuator := range device.Actuators {
 		if xlppChan(actuator.Meta) == channel {
 			return nil
 		}
 	}
-	d := actuatorMapping[t]
-	return edge.PostActuator(device.ID, &edge.Actuator{
-		Name: typeName(xlpp.Registry[t]()),
+	d := o.apply(actuatorMapping[t])
+	name := o.Name
+	if name == "" {
+		name = typeName(xlpp.Registry[t]())
+	}
+	return store.PostActuator(device.ID, &edge.Actuator{
+		ID:   o.ID,
+		Name: name,
 		Meta: edge.Meta{
 			"kind":      d.Kind,
 			"quantity":  d.Quantity,
 			"unit":      d.Unit,
 			"xlppChan":  channel,
+			"xlppType":  int(t),
 			"createdBy": "codec:xlpp",
 		},
 	})
 }
 
-func createSensor(device *edge.Device, channel int, value xlpp.Value, t time.Time) error {
+func createSensor(store edge.DeviceStore, device *edge.Device, channel int, value xlpp.Value, t time.Time, o ChannelOverride) error {
+	if o.Ignore {
+		return nil
+	}
+	scaled := o.scale(value)
 	for _, sensor := range device.Sensors {
 		if xlppChan(sensor.Meta) == channel {
-			v := edge.NewValue(value, t)
-			if _, err := edge.PostSensorValue(device.ID, sensor.ID, v); err != nil {
+			v := edge.NewValue(scaled, t)
+			if _, err := store.PostSensorValue(device.ID, sensor.ID, v); err != nil {
 				return err
 			}
 			return nil
 		}
 	}
-	d := sensorMapping[value.XLPPType()]
-	return edge.PostSensor(device.ID, &edge.Sensor{
-		Name:  typeName(value),
-		Value: value,
+	d := o.apply(sensorMapping[value.XLPPType()])
+	name := o.Name
+	if name == "" {
+		name = typeName(value)
+	}
+	return store.PostSensor(device.ID, &edge.Sensor{
+		ID:    o.ID,
+		Name:  name,
+		Value: scaled,
 		Time:  &t,
 		Meta: edge.Meta{
 			"kind":      d.Kind,
@@ -114,3 +133,17 @@ func xlppChan(m edge.Meta) int {
 	}
 	return -1
 }
+
+// xlppType returns the XLPP type that the actuator was created from, or 255 if unknown.
+func xlppType(m edge.Meta) xlpp.Type {
+	c, ok := m["xlppType"]
+	if ok {
+		if d, ok := c.(float64); ok {
+			return xlpp.Type(d)
+		}
+		if d, ok := c.(int); ok {
+			return xlpp.Type(d)
+		}
+	}
+	return 255
+}
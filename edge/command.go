@@ -0,0 +1,359 @@
+package edge
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// CommandState is the state of an actuator command.
+type CommandState string
+
+// Commands are created 'queued', become 'sent' when the downlink has been
+// fetched for the device and end as 'acknowledged', 'failed' or 'expired'.
+const (
+	CommandQueued       CommandState = "queued"
+	CommandSent         CommandState = "sent"
+	CommandAcknowledged CommandState = "acknowledged"
+	CommandFailed       CommandState = "failed"
+	CommandExpired      CommandState = "expired"
+)
+
+// commandTransitions lists the states a command may change to from each state.
+// A repeated 'sent' only updates the time the command was sent.
+var commandTransitions = map[CommandState][]CommandState{
+	CommandQueued: {CommandSent, CommandAcknowledged, CommandFailed, CommandExpired},
+	CommandSent:   {CommandSent, CommandAcknowledged, CommandFailed, CommandExpired},
+}
+
+// canChangeCommandState tells if a command in state 'from' may change to state 'to'.
+func canChangeCommandState(from CommandState, to CommandState) bool {
+	for _, state := range commandTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// commandStatesBefore returns the states that may change to that state.
+func commandStatesBefore(to CommandState) []CommandState {
+	var states []CommandState
+	for _, from := range []CommandState{CommandQueued, CommandSent} {
+		if canChangeCommandState(from, to) {
+			states = append(states, from)
+		}
+	}
+	return states
+}
+
+// DefaultCommandTTL is the time a command may stay pending before it expires.
+// Use the actuator meta 'commandTTL' to change this for one actuator.
+var DefaultCommandTTL = time.Hour * 24
+
+// Command is an actuator value that is delivered to the device.
+type Command struct {
+	ID         bson.ObjectId `json:"id" bson:"_id"`
+	DeviceID   string        `json:"deviceId" bson:"deviceId"`
+	ActuatorID string        `json:"actuatorId" bson:"actuatorId"`
+	Value      interface{}   `json:"value" bson:"value"`
+	State      CommandState  `json:"state" bson:"state"`
+	Error      string        `json:"error,omitempty" bson:"error,omitempty"`
+	Created    time.Time     `json:"created" bson:"created"`
+	Sent       *time.Time    `json:"sent" bson:"sent"`
+	Done       *time.Time    `json:"done" bson:"done"`
+	Expires    time.Time     `json:"expires" bson:"expires"`
+}
+
+// CommandReport is the report of a device or codec about a command.
+// If the ID is empty, the report applies to the oldest pending command of that actuator.
+type CommandReport struct {
+	ID         string       `json:"id"`
+	ActuatorID string       `json:"actuator"`
+	State      CommandState `json:"state"`
+	Error      string       `json:"error"`
+}
+
+// CommandCallback is called when the state of a command changes.
+type CommandCallback func(cmd *Command)
+
+var commandCallback CommandCallback
+
+// OnCommand sets the global CommandCallback handler.
+func OnCommand(cb CommandCallback) {
+	commandCallback = cb
+}
+
+func onCommand(cmd *Command) {
+	if commandCallback != nil {
+		commandCallback(cmd)
+	}
+}
+
+var errBadCommandState = CodeError{400, "invalid state, use 'sent', 'acknowledged' or 'failed'"}
+var errCommandNotFound = CodeError{404, "command not found"}
+var errCommandDone = CodeError{409, "command already completed"}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PostCommand stores the value for this actuator and queues it as a new command.
+// A zero ttl uses the actuator meta 'commandTTL' or DefaultCommandTTL.
+func PostCommand(deviceID string, actuatorID string, val Value, ttl time.Duration) (*Command, Meta, error) {
+
+	meta, err := PostActuatorValue(deviceID, actuatorID, val)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := newCommand(deviceID, actuatorID, val.Value, meta, ttl, time.Now())
+	if err := dbCommands.Insert(cmd); err != nil {
+		return nil, nil, CodeError{500, "database error: " + err.Error()}
+	}
+
+	onCommand(cmd)
+	return cmd, meta, nil
+}
+
+// newCommand creates a queued command that expires after the ttl.
+// A zero ttl uses the actuator meta 'commandTTL' or DefaultCommandTTL.
+func newCommand(deviceID string, actuatorID string, value interface{}, meta Meta, ttl time.Duration, now time.Time) *Command {
+	if ttl == 0 {
+		ttl = meta.CommandTTL()
+	}
+	return &Command{
+		ID:         newID(now),
+		DeviceID:   deviceID,
+		ActuatorID: actuatorID,
+		Value:      value,
+		State:      CommandQueued,
+		Created:    now,
+		Expires:    now.Add(ttl),
+	}
+}
+
+// GetCommand returns the actuator command with that id.
+func GetCommand(deviceID string, actuatorID string, commandID string) (*Command, error) {
+
+	if !bson.IsObjectIdHex(commandID) {
+		return nil, errCommandNotFound
+	}
+	expireCommands()
+
+	var cmd Command
+	err := dbCommands.Find(bson.M{
+		"_id":        bson.ObjectIdHex(commandID),
+		"deviceId":   deviceID,
+		"actuatorId": actuatorID,
+	}).One(&cmd)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, errCommandNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &cmd, nil
+}
+
+// CommandsIter iterates over actuator commands. Call .Next() to get the next command.
+type CommandsIter struct {
+	cmd    Command
+	dbIter *mgo.Iter
+}
+
+// Next returns the next command or nil.
+func (iter *CommandsIter) Next() (*Command, error) {
+	if iter.dbIter.Next(&iter.cmd) {
+		return &iter.cmd, iter.dbIter.Err()
+	}
+	return nil, io.EOF
+}
+
+// Close closes the iterator.
+func (iter *CommandsIter) Close() error {
+	return iter.dbIter.Close()
+}
+
+// GetCommands returns an iterator over the commands of this actuator, newest first.
+// An empty state returns commands in all states.
+func GetCommands(deviceID string, actuatorID string, state CommandState, limit int) *CommandsIter {
+
+	expireCommands()
+
+	m := bson.M{
+		"deviceId":   deviceID,
+		"actuatorId": actuatorID,
+	}
+	if state != "" {
+		m["state"] = state
+	}
+	q := dbCommands.Find(m).Sort("-_id")
+	if limit != 0 {
+		q.Limit(limit)
+	}
+	return &CommandsIter{
+		dbIter: q.Iter(),
+	}
+}
+
+// ReportCommand changes the state of a pending command.
+func ReportCommand(deviceID string, report *CommandReport) (*Command, error) {
+
+	if report.State != CommandSent && report.State != CommandAcknowledged && report.State != CommandFailed {
+		return nil, errBadCommandState
+	}
+	expireCommands()
+
+	m := bson.M{
+		"deviceId": deviceID,
+		"state":    bson.M{"$in": commandStatesBefore(report.State)},
+	}
+	if report.ActuatorID != "" {
+		m["actuatorId"] = report.ActuatorID
+	}
+	if report.ID != "" {
+		if !bson.IsObjectIdHex(report.ID) {
+			return nil, errCommandNotFound
+		}
+		m["_id"] = bson.ObjectIdHex(report.ID)
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"state": report.State,
+	}
+	if report.State == CommandSent {
+		set["sent"] = now
+	} else {
+		set["done"] = now
+		if report.Error != "" {
+			set["error"] = report.Error
+		}
+	}
+
+	var cmd Command
+	_, err := dbCommands.Find(m).Sort("_id").Apply(mgo.Change{
+		Update:    bson.M{"$set": set},
+		ReturnNew: true,
+	}, &cmd)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			if report.ID != "" {
+				n, _ := dbCommands.Find(bson.M{
+					"_id":      bson.ObjectIdHex(report.ID),
+					"deviceId": deviceID,
+				}).Count()
+				if n != 0 {
+					return nil, errCommandDone
+				}
+			}
+			return nil, errCommandNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+
+	onCommand(&cmd)
+	return &cmd, nil
+}
+
+// ReportCommands applies all reports of a device, e.g. as decoded by a codec.
+func ReportCommands(deviceID string, reports []CommandReport) {
+	for i := range reports {
+		if _, err := ReportCommand(deviceID, &reports[i]); err != nil {
+			log.Printf("[ERR  ] Command report %s %+v: %v", deviceID, reports[i], err)
+		}
+	}
+}
+
+// setCommandsSent marks all queued commands of the device as sent.
+// This is called when the downlink payload has been created for the device.
+func setCommandsSent(deviceID string) {
+
+	var cmds []Command
+	err := dbCommands.Find(bson.M{
+		"deviceId": deviceID,
+		"state":    CommandQueued,
+	}).All(&cmds)
+	if err != nil {
+		log.Printf("[ERR  ] Commands %s: %v", deviceID, err)
+		return
+	}
+	if len(cmds) == 0 {
+		return
+	}
+
+	now := time.Now()
+	ids := make([]bson.ObjectId, len(cmds))
+	for i := range cmds {
+		ids[i] = cmds[i].ID
+	}
+	_, err = dbCommands.UpdateAll(bson.M{
+		"_id":   bson.M{"$in": ids},
+		"state": CommandQueued,
+	}, bson.M{
+		"$set": bson.M{
+			"state": CommandSent,
+			"sent":  now,
+		},
+	})
+	if err != nil {
+		log.Printf("[ERR  ] Commands %s: %v", deviceID, err)
+		return
+	}
+	for i := range cmds {
+		cmds[i].State = CommandSent
+		cmds[i].Sent = &now
+		onCommand(&cmds[i])
+	}
+}
+
+// expireCommands marks all pending commands after their TTL as expired.
+func expireCommands() {
+
+	now := time.Now()
+	m := bson.M{
+		"state":   bson.M{"$in": commandStatesBefore(CommandExpired)},
+		"expires": bson.M{"$lte": now},
+	}
+
+	var cmds []Command
+	if err := dbCommands.Find(m).All(&cmds); err != nil {
+		log.Printf("[ERR  ] Commands: %v", err)
+		return
+	}
+	if len(cmds) == 0 {
+		return
+	}
+
+	ids := make([]bson.ObjectId, len(cmds))
+	for i := range cmds {
+		ids[i] = cmds[i].ID
+	}
+	m["_id"] = bson.M{"$in": ids}
+	_, err := dbCommands.UpdateAll(m, bson.M{
+		"$set": bson.M{
+			"state": CommandExpired,
+			"done":  now,
+		},
+	})
+	if err != nil {
+		log.Printf("[ERR  ] Commands: %v", err)
+		return
+	}
+	for i := range cmds {
+		cmds[i].State = CommandExpired
+		cmds[i].Done = &now
+		onCommand(&cmds[i])
+	}
+}
+
+// deleteCommands removes all commands of that actuator.
+func deleteCommands(deviceID string, actuatorID string) {
+	m := bson.M{"deviceId": deviceID}
+	if actuatorID != "" {
+		m["actuatorId"] = actuatorID
+	}
+	dbCommands.RemoveAll(m)
+}
@@ -0,0 +1,71 @@
+package edge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewCommand(t *testing.T) {
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		meta    Meta
+		ttl     time.Duration
+		expires time.Time
+	}{
+		{Meta{"commandTTL": "5m"}, 0, now.Add(5 * time.Minute)},
+		{Meta{"commandTTL": "5m"}, time.Hour, now.Add(time.Hour)},
+		{Meta{"commandTTL": "soon"}, 0, now.Add(DefaultCommandTTL)},
+		{nil, 0, now.Add(DefaultCommandTTL)},
+	}
+
+	for _, test := range tests {
+		cmd := newCommand("d1", "a1", 1.0, test.meta, test.ttl, now)
+		if cmd.State != CommandQueued {
+			t.Errorf("%v: state %q, want %q", test.meta, cmd.State, CommandQueued)
+		}
+		if !cmd.Expires.Equal(test.expires) {
+			t.Errorf("%v, ttl %v: expires %v, want %v", test.meta, test.ttl, cmd.Expires, test.expires)
+		}
+	}
+}
+
+func TestCommandStates(t *testing.T) {
+
+	tests := []struct {
+		from CommandState
+		to   CommandState
+		ok   bool
+	}{
+		{CommandQueued, CommandSent, true},
+		{CommandQueued, CommandAcknowledged, true},
+		{CommandQueued, CommandFailed, true},
+		{CommandQueued, CommandExpired, true},
+		{CommandSent, CommandSent, true},
+		{CommandSent, CommandAcknowledged, true},
+		{CommandSent, CommandFailed, true},
+		{CommandSent, CommandExpired, true},
+		{CommandSent, CommandQueued, false},
+		{CommandAcknowledged, CommandFailed, false},
+		{CommandAcknowledged, CommandExpired, false},
+		{CommandFailed, CommandSent, false},
+		{CommandExpired, CommandAcknowledged, false},
+		{CommandExpired, CommandSent, false},
+	}
+
+	for _, test := range tests {
+		if ok := canChangeCommandState(test.from, test.to); ok != test.ok {
+			t.Errorf("%s -> %s: got %v, want %v", test.from, test.to, ok, test.ok)
+		}
+	}
+
+	pending := []CommandState{CommandQueued, CommandSent}
+	if states := commandStatesBefore(CommandExpired); !reflect.DeepEqual(states, pending) {
+		t.Errorf("states before %q: got %v, want %v", CommandExpired, states, pending)
+	}
+	if states := commandStatesBefore(CommandQueued); len(states) != 0 {
+		t.Errorf("states before %q: got %v, want none", CommandQueued, states)
+	}
+}
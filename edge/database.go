@@ -18,15 +18,36 @@ var dbActuatorValues *mgo.Collection
 // dbDevices is the database holding devices' information
 var dbDevices *mgo.Collection
 
+// dbCommands is the database holding actuator commands
+var dbCommands *mgo.Collection
+
 // dbCodecs is the database holding codecs & scripts
 var dbCodecs *mgo.Collection
 
+// dbCodecVersions is the database holding all versions of the codecs
+var dbCodecVersions *mgo.Collection
+
+// dbTemplates is the database holding device templates
+var dbTemplates *mgo.Collection
+
 // dbMessages is the database holding wazigate messages
 var dbMessages *mgo.Collection
 
 // dbUsers is the database holding users' information
 var dbUsers *mgo.Collection
 
+// dbAPIKeys is the database holding the (hashed) api keys
+var dbAPIKeys *mgo.Collection
+
+// dbSessions is the database holding the login sessions
+var dbSessions *mgo.Collection
+
+// dbAudit is the (capped) database holding the audit log
+var dbAudit *mgo.Collection
+
+// auditMaxBytes is the size of the audit log, older entries are dropped.
+const auditMaxBytes = 64 << 20
+
 // dbConfig is the database holding the configurations in a key-value form
 var dbConfig *mgo.Collection
 
@@ -48,10 +69,23 @@ func ConnectWithInfo(info *mgo.DialInfo) error {
 		dbSensorValues = db.DB("waziup").C("sensor_values")
 		dbActuatorValues = db.DB("waziup").C("actuator_values")
 		dbDevices = db.DB("waziup").C("devices")
+		dbCommands = db.DB("waziup").C("actuator_commands")
 		dbMessages = db.DB("waziup").C("messages")
 		dbCodecs = db.DB("waziup").C("codecs")
+		dbCodecVersions = db.DB("waziup").C("codec_versions")
+		dbTemplates = db.DB("waziup").C("device_templates")
 		dbUsers = db.DB("waziup").C("users")
+		dbAPIKeys = db.DB("waziup").C("api_keys")
+		dbAPIKeys.EnsureIndex(mgo.Index{Key: []string{"hash"}, Unique: true})
+		dbSessions = db.DB("waziup").C("sessions")
+		dbSessions.EnsureIndex(mgo.Index{Key: []string{"userId"}})
+		dbSessions.EnsureIndex(mgo.Index{Key: []string{"expires"}, ExpireAfter: time.Second})
 		dbConfig = db.DB("waziup").C("config")
+		dbAudit = db.DB("waziup").C("audit")
+		// creating fails if the collection exists already
+		dbAudit.Create(&mgo.CollectionInfo{Capped: true, MaxBytes: auditMaxBytes})
+		dbAudit.EnsureIndex(mgo.Index{Key: []string{"actor.type", "actor.id"}})
+		dbAudit.EnsureIndex(mgo.Index{Key: []string{"entity"}})
 
 		err = CheckCustomJSCodecsAvailable()
 		if err != nil {
@@ -96,6 +130,7 @@ func CheckCustomJSCodecsAvailable() error {
 				Mime:      "application/javascript",
 				ServeMime: "application/waziup." + strings.ReplaceAll(codecName, " ", ""),
 				Script:    string(data),
+				Author:    "system",
 			}
 
 			// Post codec
@@ -1,6 +1,7 @@
 package edge
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -29,6 +30,8 @@ type Device struct {
 	Actuators []*Actuator `json:"actuators" bson:"actuators"`
 	Modified  time.Time   `json:"modified" bson:"modified"`
 	Created   time.Time   `json:"created" bson:"created"`
+	LastSeen  *time.Time  `json:"lastSeen" bson:"lastSeen"`
+	Tags      []string    `json:"tags" bson:"tags"`
 	Meta      Meta        `json:"meta" bson:"meta"`
 
 	jsonSelect []string
@@ -44,6 +47,13 @@ type Query struct {
 	Size   int64
 	Meta   []string
 	Select []string
+	Tags   []string
+	// Filter is a filter expression, see ParseFilter.
+	Filter string
+	// Sort lists the fields to sort by, like "name" or "-modified".
+	Sort []string
+	// Cursor continues a previous query, see DeviceIterator.Cursor.
+	Cursor string
 }
 
 func (device *Device) MarshalJSON() ([]byte, error) {
@@ -130,6 +140,25 @@ func (query *Query) Parse(values url.Values) error {
 			query.Select[i] = strings.TrimSpace(str)
 		}
 	}
+	if param = values.Get("tag"); param != "" {
+		query.Tags = cleanTags(strings.Split(param, ","))
+	}
+	if param = values.Get("filter"); param != "" {
+		if _, err = ParseFilter(param); err != nil {
+			return err
+		}
+		query.Filter = param
+	}
+	if param = values.Get("sort"); param != "" {
+		query.Sort = strings.Split(param, ",")
+		for i, str := range query.Sort {
+			query.Sort[i] = strings.TrimSpace(str)
+		}
+		if _, err = parseSort(query.Sort); err != nil {
+			return err
+		}
+	}
+	query.Cursor = values.Get("cursor")
 	return nil
 }
 
@@ -216,20 +245,40 @@ func GenerateNewGatewayID() string {
 type DeviceIterator struct {
 	device Device
 	dbIter *mgo.Iter
+	err    error
+
+	sort   []sortKey
+	cursor string
 }
 
 // Next returns the next device or nil.
 func (iter *DeviceIterator) Next() (*Device, error) {
+	if iter.err != nil {
+		return nil, iter.err
+	}
 	jsonSelect := iter.device.jsonSelect
 	if iter.dbIter.Next(&iter.device) {
 		iter.device.jsonSelect = jsonSelect
+		iter.cursor = ""
 		return &iter.device, iter.dbIter.Err()
 	}
 	return nil, io.EOF
 }
 
+// Cursor returns a cursor that can be used as Query.Cursor
+// to continue after the last device returned by Next.
+func (iter *DeviceIterator) Cursor() string {
+	if iter.cursor == "" && iter.device.ID != "" {
+		iter.cursor = encodeCursor(iter.sort, &iter.device)
+	}
+	return iter.cursor
+}
+
 // Close closes the iterator.
 func (iter *DeviceIterator) Close() error {
+	if iter.dbIter == nil {
+		return iter.err
+	}
 	return iter.dbIter.Close()
 }
 
@@ -237,14 +286,50 @@ func (iter *DeviceIterator) Close() error {
 func GetDevices(query *Query) *DeviceIterator {
 
 	sel := bson.M{}
+	and := []bson.M{}
+	var sort []sortKey
+	var err error
 	if query != nil {
 		if len(query.Meta) != 0 {
 			for _, name := range query.Meta {
 				sel["meta."+name] = bson.M{"$exists": true}
 			}
 		}
+		if len(query.Tags) != 0 {
+			sel["tags"] = bson.M{"$all": query.Tags}
+		}
+		if query.Filter != "" {
+			filter, err := ParseFilter(query.Filter)
+			if err != nil {
+				return &DeviceIterator{err: err}
+			}
+			and = append(and, filter)
+		}
+	}
+	if query != nil {
+		sort, err = parseSort(query.Sort)
+	} else {
+		sort, err = parseSort(nil)
+	}
+	if err != nil {
+		return &DeviceIterator{err: err}
+	}
+	if query != nil && query.Cursor != "" {
+		after, err := cursorSelector(sort, query.Cursor)
+		if err != nil {
+			return &DeviceIterator{err: err}
+		}
+		and = append(and, after)
+	}
+	if len(and) != 0 {
+		sel["$and"] = and
 	}
-	q := dbDevices.Find(sel)
+
+	sortFields := make([]string, len(sort))
+	for i, key := range sort {
+		sortFields[i] = key.String()
+	}
+	q := dbDevices.Find(sel).Sort(sortFields...)
 	var jsonSelect []string
 	if query != nil {
 		if query.Select != nil {
@@ -261,6 +346,9 @@ func GetDevices(query *Query) *DeviceIterator {
 					s["actuators.id"] = 1
 				}
 			}
+			for _, key := range sort {
+				s[key.field] = 1
+			}
 			q.Select(s)
 		}
 		if query.Limit != 0 {
@@ -273,6 +361,7 @@ func GetDevices(query *Query) *DeviceIterator {
 		device: Device{
 			jsonSelect: jsonSelect,
 		},
+		sort: sort,
 	}
 }
 
@@ -343,6 +432,7 @@ func PostDevices(device *Device) error {
 	now := time.Now()
 	device.Created = now
 	device.Modified = now
+	device.Tags = cleanTags(device.Tags)
 
 	if device.Sensors != nil {
 		for _, sensor := range device.Sensors {
@@ -420,14 +510,33 @@ func PostDevices(device *Device) error {
 // This might be JSON data, LoRaWAN XLPP payload or something else.
 func UnmarshalDevice(deviceID string, headers http.Header, r io.Reader) error {
 
-	_, codec, err := FindCodec(deviceID, headers.Get("Content-Type"))
+	name, codec, err := FindCodecFor(deviceID, headers.Get("Content-Type"), headers)
 	if err != nil {
 		return err
 	}
-	return codec.UnmarshalDevice(deviceID, headers, r)
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = codec.UnmarshalDevice(DB, deviceID, headers, bytes.NewReader(payload))
+	recordCodecCall(name, deviceID, "uplink", payload, time.Since(start), err)
+	if err != nil {
+		return err
+	}
+	return SetDeviceLastSeen(deviceID, time.Now())
 }
 
+// FindCodec returns the codec for that content type.
+// For "application/octet-stream", the device meta 'codec' is used.
 func FindCodec(deviceID string, contentType string) (name string, codec Codec, err error) {
+	return FindCodecFor(deviceID, contentType, nil)
+}
+
+// FindCodecFor is like FindCodec, but uses the routes of the device meta 'codecs'
+// to select a codec by the request headers (like the LoRaWAN fPort) before
+// falling back to the meta 'codec'.
+func FindCodecFor(deviceID string, contentType string, headers http.Header) (name string, codec Codec, err error) {
 	var ok bool
 
 	warnNoDefaultCodec := false
@@ -453,6 +562,9 @@ func FindCodec(deviceID string, contentType string) (name string, codec Codec, e
 				return "", nil, err
 			}
 			defaultCodecName := meta["codec"]
+			if route := Meta(meta).RouteCodec(headers); route != "" {
+				defaultCodecName = route
+			}
 			if defaultCodecName == nil {
 				warnNoDefaultCodec = true
 				continue
@@ -467,6 +579,16 @@ func FindCodec(deviceID string, contentType string) (name string, codec Codec, e
 					warnDefaultCodecUnavailable = true
 					continue
 				}
+			} else if codecID, version := splitCodecVersion(name); version != 0 {
+				script, err := GetCodecVersion(codecID, version)
+				if err != nil {
+					if err == errVersionNotFound {
+						warnDefaultCodecUnavailable = true
+						continue
+					}
+					return "", nil, err
+				}
+				codec = script
 			} else {
 				var script ScriptCodec
 				err := dbCodecs.FindId(name).One(&script)
@@ -502,15 +624,42 @@ func FindCodec(deviceID string, contentType string) (name string, codec Codec, e
 	return
 }
 
+// DownlinkWriter is the writer that MarshalDevice passes to codecs.
+// Codecs can set headers of the downlink with Header(), like the 'X-LoRaWAN-FPort'.
+type DownlinkWriter struct {
+	io.Writer
+	header http.Header
+}
+
+// Header returns the headers of the downlink.
+func (d *DownlinkWriter) Header() http.Header {
+	if d.header == nil {
+		d.header = http.Header{}
+	}
+	return d.header
+}
+
 // MarshalDevice writes complex data to the device.
 // This might be JSON data, LoRaWAN XLPP payload or something else.
-func MarshalDevice(deviceID string, headers http.Header, w io.Writer) (string, error) {
+// It returns the codec mime and the headers that the codec set for the downlink.
+func MarshalDevice(deviceID string, headers http.Header, w io.Writer) (string, http.Header, error) {
 
-	name, codec, err := FindCodec(deviceID, headers.Get("Accept"))
+	name, codec, err := FindCodecFor(deviceID, headers.Get("Accept"), headers)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	downlink := &DownlinkWriter{Writer: w}
+	start := time.Now()
+	err = codec.MarshalDevice(DB, deviceID, headers, downlink)
+	recordCodecCall(name, deviceID, "downlink", nil, time.Since(start), err)
+	if err != nil {
+		return name, nil, err
+	}
+	// The plain JSON representation is not a downlink.
+	if name != "application/json" {
+		setCommandsSent(deviceID)
 	}
-	return name, codec.MarshalDevice(deviceID, headers, w)
+	return name, downlink.Header(), nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -642,6 +791,7 @@ func DeleteDevice(deviceID string) (*Device, int, int, error) {
 	err = dbDevices.RemoveId(deviceID)
 	infoS, _ := dbSensorValues.RemoveAll(bson.M{"deviceId": deviceID})
 	infoA, _ := dbActuatorValues.RemoveAll(bson.M{"deviceId": deviceID})
+	deleteCommands(deviceID, "")
 	numS := infoS.Removed
 	numA := infoA.Removed
 
@@ -0,0 +1,429 @@
+package edge
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge/ontology"
+	"github.com/globalsign/mgo/bson"
+)
+
+// A filter is a boolean expression of comparisons on device fields, e.g.
+//
+//	meta.codec==application/x-xlpp and sensors.quantity==Temperature and modified>2026-01-01
+//
+// Comparisons are 'field op value' with op one of == != > >= < <= and =~ (regular expression).
+// Comparisons can be combined with 'and', 'or', 'not' and parentheses.
+// Values can be quoted with "..." or '...' and are otherwise parsed as number, boolean, null or string.
+
+type filterToken struct {
+	kind  byte // '(' ')' 'o' (operator) 'w' (word) 's' (quoted string)
+	value string
+}
+
+var filterOperators = []string{"==", "!=", ">=", "<=", "=~", ">", "<"}
+
+func tokenizeFilter(str string) ([]filterToken, error) {
+	var tokens []filterToken
+	i := 0
+TOKENS:
+	for i < len(str) {
+		c := str[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{kind: c, value: string(c)})
+			i++
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(str[i+1:], c)
+			if j == -1 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: 's', value: str[i+1 : i+1+j]})
+			i += j + 2
+		default:
+			for _, op := range filterOperators {
+				if strings.HasPrefix(str[i:], op) {
+					tokens = append(tokens, filterToken{kind: 'o', value: op})
+					i += len(op)
+					continue TOKENS
+				}
+			}
+			j := i
+			for j < len(str) && !strings.ContainsRune(" \t\n\r()\"'=!<>", rune(str[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at %d", str[i], i)
+			}
+			tokens = append(tokens, filterToken{kind: 'w', value: str[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return len(p.tokens) != 0 && p.tokens[0].kind == 'w' && strings.EqualFold(p.tokens[0].value, keyword)
+}
+
+func (p *filterParser) parseOr() (bson.M, error) {
+	sel, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := []bson.M{sel}
+	for p.peekKeyword("or") {
+		p.tokens = p.tokens[1:]
+		sel, err = p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, sel)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return bson.M{"$or": or}, nil
+}
+
+func (p *filterParser) parseAnd() (bson.M, error) {
+	sel, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	and := []bson.M{sel}
+	for p.peekKeyword("and") {
+		p.tokens = p.tokens[1:]
+		sel, err = p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, sel)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return bson.M{"$and": and}, nil
+}
+
+func (p *filterParser) parseNot() (bson.M, error) {
+	if p.peekKeyword("not") {
+		p.tokens = p.tokens[1:]
+		sel, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nor": []bson.M{sel}}, nil
+	}
+	if len(p.tokens) != 0 && p.tokens[0].kind == '(' {
+		p.tokens = p.tokens[1:]
+		sel, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if len(p.tokens) == 0 || p.tokens[0].kind != ')' {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.tokens = p.tokens[1:]
+		return sel, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (bson.M, error) {
+	if len(p.tokens) < 3 {
+		return nil, fmt.Errorf("incomplete comparison")
+	}
+	field, op, value := p.tokens[0], p.tokens[1], p.tokens[2]
+	if field.kind != 'w' {
+		return nil, fmt.Errorf("expected a field name")
+	}
+	if op.kind != 'o' {
+		return nil, fmt.Errorf("expected an operator after %q", field.value)
+	}
+	if value.kind != 'w' && value.kind != 's' {
+		return nil, fmt.Errorf("expected a value after %q", field.value+op.value)
+	}
+	p.tokens = p.tokens[3:]
+
+	key, err := filterField(field.value)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.value == "=~" {
+		if _, err := regexp.Compile(value.value); err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", value.value, err)
+		}
+		return bson.M{key: bson.M{"$regex": value.value}}, nil
+	}
+
+	v, err := filterValue(field.value, value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.value {
+	case "==":
+		return bson.M{key: v}, nil
+	case "!=":
+		return bson.M{key: bson.M{"$ne": v}}, nil
+	case ">":
+		return bson.M{key: bson.M{"$gt": v}}, nil
+	case ">=":
+		return bson.M{key: bson.M{"$gte": v}}, nil
+	case "<":
+		return bson.M{key: bson.M{"$lt": v}}, nil
+	default: // "<="
+		return bson.M{key: bson.M{"$lte": v}}, nil
+	}
+}
+
+var filterStringFields = map[string]bool{
+	"id": true, "name": true, "tags": true,
+	"sensors.id": true, "sensors.name": true,
+	"actuators.id": true, "actuators.name": true,
+}
+
+var filterTimeFields = map[string]bool{
+	"modified": true, "created": true, "lastSeen": true,
+	"sensors.modified": true, "sensors.created": true, "sensors.time": true,
+	"actuators.modified": true, "actuators.created": true, "actuators.time": true,
+}
+
+var filterOntologyFields = map[string]bool{
+	"sensors.kind": true, "sensors.quantity": true, "sensors.unit": true,
+}
+
+func isFilterMetaField(field string) bool {
+	return (strings.HasPrefix(field, "meta.") ||
+		strings.HasPrefix(field, "sensors.meta.") ||
+		strings.HasPrefix(field, "actuators.meta.")) && !strings.HasSuffix(field, ".")
+}
+
+// filterField maps the field name to the database key.
+func filterField(field string) (string, error) {
+	if field == "id" {
+		return "_id", nil
+	}
+	if filterStringFields[field] || filterTimeFields[field] || filterOntologyFields[field] ||
+		field == "sensors.value" || field == "actuators.value" || isFilterMetaField(field) {
+		return field, nil
+	}
+	return "", fmt.Errorf("unknown field %q", field)
+}
+
+var filterTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// filterValue converts the token to the type that is stored in the database for that field.
+func filterValue(field string, token filterToken) (interface{}, error) {
+	str := token.value
+	if token.kind == 'w' && str == "null" {
+		return nil, nil
+	}
+	switch {
+	case filterStringFields[field]:
+		return str, nil
+	case filterTimeFields[field]:
+		for _, layout := range filterTimeLayouts {
+			if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid time %q for %q", str, field)
+	case field == "sensors.kind":
+		if k, ok := ontology.ParseSensingKind(str); ok {
+			return k, nil
+		}
+	case field == "sensors.quantity":
+		if q, ok := ontology.ParseQuantity(str); ok {
+			return q, nil
+		}
+	case field == "sensors.unit":
+		if u, ok := ontology.ParseUnit(str); ok {
+			return u, nil
+		}
+	default:
+		if token.kind == 's' {
+			return str, nil
+		}
+		if str == "true" || str == "false" {
+			return str == "true", nil
+		}
+		if f, err := strconv.ParseFloat(str, 64); err == nil {
+			return f, nil
+		}
+		return str, nil
+	}
+	return nil, fmt.Errorf("unknown %s %q", field[8:], str)
+}
+
+// ParseFilter compiles the filter expression to a database selector.
+func ParseFilter(filter string) (bson.M, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, CodeError{400, "invalid filter: " + err.Error()}
+	}
+	if len(tokens) == 0 {
+		return bson.M{}, nil
+	}
+	p := filterParser{tokens}
+	sel, err := p.parseOr()
+	if err == nil && len(p.tokens) != 0 {
+		err = fmt.Errorf("unexpected %q", p.tokens[0].value)
+	}
+	if err != nil {
+		return nil, CodeError{400, "invalid filter: " + err.Error()}
+	}
+	return sel, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// parseSort reads sort fields like "name" or "-modified".
+// The device id is always appended to make the order unique.
+func parseSort(fields []string) ([]sortKey, error) {
+	keys := make([]sortKey, 0, len(fields)+1)
+	hasID := false
+	for _, field := range fields {
+		var key sortKey
+		if strings.HasPrefix(field, "-") {
+			key.desc = true
+			field = field[1:]
+		} else {
+			field = strings.TrimPrefix(field, "+")
+		}
+		switch {
+		case field == "id":
+			key.field = "_id"
+			hasID = true
+		case field == "name" || field == "modified" || field == "created" || field == "lastSeen":
+			key.field = field
+		case strings.HasPrefix(field, "meta.") && len(field) > 5:
+			key.field = field
+		default:
+			return nil, CodeError{400, fmt.Sprintf("can not sort by %q", field)}
+		}
+		keys = append(keys, key)
+		if hasID {
+			break
+		}
+	}
+	if !hasID {
+		keys = append(keys, sortKey{field: "_id"})
+	}
+	return keys, nil
+}
+
+func (key sortKey) String() string {
+	if key.desc {
+		return "-" + key.field
+	}
+	return key.field
+}
+
+// sortValue returns the value of the sort field for that device.
+func (key sortKey) value(device *Device) interface{} {
+	switch key.field {
+	case "_id":
+		return device.ID
+	case "name":
+		return device.Name
+	case "modified":
+		return device.Modified
+	case "created":
+		return device.Created
+	case "lastSeen":
+		if device.LastSeen == nil {
+			return nil
+		}
+		return *device.LastSeen
+	}
+	var v interface{} = map[string]interface{}(device.Meta)
+	for _, name := range strings.Split(key.field[5:], ".") {
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v = m[name]
+		case Meta:
+			v = m[name]
+		case bson.M:
+			v = m[name]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+type deviceCursor struct {
+	Values []interface{} `bson:"v"`
+}
+
+func encodeCursor(keys []sortKey, device *Device) string {
+	cursor := deviceCursor{Values: make([]interface{}, len(keys))}
+	for i, key := range keys {
+		cursor.Values[i] = key.value(device)
+	}
+	data, err := bson.Marshal(&cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+var errBadCursor = CodeError{400, "invalid cursor"}
+
+// cursorSelector returns a selector for all devices after the cursor in the sort order.
+func cursorSelector(keys []sortKey, str string) (bson.M, error) {
+	data, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	var cursor deviceCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil || len(cursor.Values) != len(keys) {
+		return nil, errBadCursor
+	}
+
+	or := make([]bson.M, 0, len(keys))
+	for i, key := range keys {
+		sel := bson.M{}
+		for j := 0; j < i; j++ {
+			sel[keys[j].field] = cursor.Values[j]
+		}
+		v := cursor.Values[i]
+		// null and missing fields come first in ascending order
+		if v == nil {
+			if key.desc {
+				continue
+			}
+			sel[key.field] = bson.M{"$ne": nil}
+		} else if key.desc {
+			sel["$or"] = []bson.M{
+				{key.field: bson.M{"$lt": v}},
+				{key.field: nil},
+			}
+		} else {
+			sel[key.field] = bson.M{"$gt": v}
+		}
+		or = append(or, sel)
+	}
+	if len(or) == 0 {
+		return bson.M{"_id": bson.M{"$exists": false}}, nil
+	}
+	return bson.M{"$or": or}, nil
+}
@@ -0,0 +1,140 @@
+package edge
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge/ontology"
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestParseFilter(t *testing.T) {
+
+	temperature, _ := ontology.ParseQuantity("Temperature")
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		filter string
+		sel    bson.M
+	}{
+		{"", bson.M{}},
+		{"name==Station", bson.M{"name": "Station"}},
+		{"id == 'abc'", bson.M{"_id": "abc"}},
+		{`name != "Soil Station"`, bson.M{"name": bson.M{"$ne": "Soil Station"}}},
+		{"meta.interval>=10", bson.M{"meta.interval": bson.M{"$gte": 10.0}}},
+		{"meta.enabled==true", bson.M{"meta.enabled": true}},
+		{"meta.enabled=='true'", bson.M{"meta.enabled": "true"}},
+		{"meta.codec==null", bson.M{"meta.codec": nil}},
+		{"name==123", bson.M{"name": "123"}},
+		{"modified>2026-01-01", bson.M{"modified": bson.M{"$gt": date}}},
+		{"sensors.quantity==Temperature", bson.M{"sensors.quantity": temperature}},
+		{"name=~^Soil", bson.M{"name": bson.M{"$regex": "^Soil"}}},
+		{"sensors.value<5 and sensors.value>-5", bson.M{"$and": []bson.M{
+			{"sensors.value": bson.M{"$lt": 5.0}},
+			{"sensors.value": bson.M{"$gt": -5.0}},
+		}}},
+		{"name==a or name==b and tags==c", bson.M{"$or": []bson.M{
+			{"name": "a"},
+			{"$and": []bson.M{{"name": "b"}, {"tags": "c"}}},
+		}}},
+		{"(name==a OR name==b) and not tags==c", bson.M{"$and": []bson.M{
+			{"$or": []bson.M{{"name": "a"}, {"name": "b"}}},
+			{"$nor": []bson.M{{"tags": "c"}}},
+		}}},
+	}
+
+	for _, test := range tests {
+		sel, err := ParseFilter(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if !reflect.DeepEqual(sel, test.sel) {
+			t.Errorf("%s: got %v, want %v", test.filter, sel, test.sel)
+		}
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+
+	for _, filter := range []string{
+		"name",
+		"name==",
+		"name==a and",
+		"(name==a",
+		"name==a)",
+		"name==a name==b",
+		"password==secret",
+		"meta.==a",
+		"name=='a",
+		"modified>yesterday",
+		"sensors.quantity==Happiness",
+		"name=~(",
+		"==a",
+	} {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("%s: no error", filter)
+		} else if e, ok := err.(CodeError); !ok || e.Code != 400 {
+			t.Errorf("%s: got %v, want a 400 error", filter, err)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+
+	tests := []struct {
+		fields []string
+		keys   string
+	}{
+		{nil, "[_id]"},
+		{[]string{"name"}, "[name _id]"},
+		{[]string{"-modified", "+meta.room"}, "[-modified meta.room _id]"},
+		{[]string{"-id", "name"}, "[-_id]"},
+	}
+
+	for _, test := range tests {
+		keys, err := parseSort(test.fields)
+		if err != nil {
+			t.Errorf("%v: %v", test.fields, err)
+			continue
+		}
+		strs := make([]string, len(keys))
+		for i, key := range keys {
+			strs[i] = key.String()
+		}
+		if got := "[" + strings.Join(strs, " ") + "]"; got != test.keys {
+			t.Errorf("%v: got %s, want %s", test.fields, got, test.keys)
+		}
+	}
+
+	for _, fields := range [][]string{{"password"}, {"meta."}} {
+		if _, err := parseSort(fields); err == nil {
+			t.Errorf("%v: no error", fields)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+
+	keys, _ := parseSort([]string{"-meta.room", "name"})
+	device := &Device{ID: "d1", Name: "Station", Meta: Meta{"room": 3.0}}
+
+	sel, err := cursorSelector(keys, encodeCursor(keys, device))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{"$or": []bson.M{
+		{"$or": []bson.M{{"meta.room": bson.M{"$lt": 3.0}}, {"meta.room": nil}}},
+		{"meta.room": 3.0, "name": bson.M{"$gt": "Station"}},
+		{"meta.room": 3.0, "name": "Station", "_id": bson.M{"$gt": "d1"}},
+	}}
+	if !reflect.DeepEqual(sel, want) {
+		t.Errorf("got %v, want %v", sel, want)
+	}
+
+	if _, err := cursorSelector(keys, "not a cursor"); err != errBadCursor {
+		t.Errorf("invalid cursor: got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoginMaxFailures is the number of failed logins (per username or IP address)
+// after which logins are locked for LoginLockoutDuration.
+const LoginMaxFailures = 5
+
+// LoginLockoutDuration is the time that logins are locked after LoginMaxFailures failures.
+const LoginLockoutDuration = 15 * time.Minute
+
+// LoginFailureWindow is the time after which failed logins are forgotten.
+const LoginFailureWindow = time.Hour
+
+// loginDelayBase is the delay after the first failed login. It doubles with every failure.
+const loginDelayBase = time.Second
+
+// Lockout counts the failed logins for a username or IP address.
+type Lockout struct {
+	// ID is "user:{username}" or "ip:{address}".
+	ID          string    `json:"id"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	// RetryAfter is the time until no login is accepted for this username or IP address.
+	RetryAfter time.Time `json:"retryAfter"`
+	// Locked is set if the lockout is caused by LoginMaxFailures failures.
+	Locked bool `json:"locked"`
+}
+
+// maxLockouts is the max. number of usernames and IP addresses with failed logins.
+// If there are more, the lockouts with the oldest failure are forgotten first.
+const maxLockouts = 10000
+
+var lockouts = map[string]*Lockout{}
+var lockoutsMutex sync.Mutex
+
+var errLockoutNotFound = CodeError{404, "lockout not found"}
+
+func lockoutIDs(username string, addr string) []string {
+	return []string{"user:" + username, "ip:" + addr}
+}
+
+// CheckLogin returns the time to wait if logins are currently throttled or locked
+// for that username or IP address, or 0 if the login may be tried.
+func CheckLogin(username string, addr string) time.Duration {
+
+	lockoutsMutex.Lock()
+	defer lockoutsMutex.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, id := range lockoutIDs(username, addr) {
+		lockout := lockouts[id]
+		if lockout == nil {
+			continue
+		}
+		if w := lockout.RetryAfter.Sub(now); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// LoginFailed counts a failed login for that username and IP address.
+// Every failure doubles the time until the next login is accepted,
+// and LoginMaxFailures failures lock logins for LoginLockoutDuration.
+func LoginFailed(username string, addr string) {
+
+	lockoutsMutex.Lock()
+	defer lockoutsMutex.Unlock()
+
+	now := time.Now()
+	for _, id := range lockoutIDs(username, addr) {
+		lockout := lockouts[id]
+		if lockout == nil || now.Sub(lockout.LastFailure) > LoginFailureWindow {
+			if lockout == nil && len(lockouts) >= maxLockouts {
+				pruneLockouts(now)
+			}
+			lockout = &Lockout{ID: id}
+			lockouts[id] = lockout
+		}
+		lockout.Failures++
+		lockout.LastFailure = now
+		if lockout.Failures >= LoginMaxFailures {
+			lockout.RetryAfter = now.Add(LoginLockoutDuration)
+			if !lockout.Locked {
+				lockout.Locked = true
+				log.Printf("[WARN ] Login locked for %s after %d failed logins.", id, lockout.Failures)
+				msg := Message{
+					Title:    "Login locked",
+					Severity: "warning",
+					Target:   "users",
+					Text:     fmt.Sprintf("Logins for %s are locked for %s after %d failed logins.", id, LoginLockoutDuration, lockout.Failures),
+				}
+				if err := PostMessage(&msg); err != nil {
+					log.Printf("[ERR  ] Login lockout: %v", err)
+				}
+			}
+		} else {
+			lockout.RetryAfter = now.Add(loginDelayBase << (lockout.Failures - 1))
+			log.Printf("[WARN ] Failed login %d of %d for %s.", lockout.Failures, LoginMaxFailures, id)
+		}
+	}
+}
+
+// LoginSucceeded resets the failed logins of that username and IP address.
+func LoginSucceeded(username string, addr string) {
+
+	lockoutsMutex.Lock()
+	defer lockoutsMutex.Unlock()
+
+	for _, id := range lockoutIDs(username, addr) {
+		delete(lockouts, id)
+	}
+}
+
+// GetLockouts returns the usernames and IP addresses with recent failed logins.
+func GetLockouts() []Lockout {
+
+	lockoutsMutex.Lock()
+	defer lockoutsMutex.Unlock()
+
+	pruneLockouts(time.Now())
+	list := make([]Lockout, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		list = append(list, *lockout)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// pruneLockouts removes the lockouts that are over, and the oldest ones if there are
+// still maxLockouts or more. The lockoutsMutex must be held.
+func pruneLockouts(now time.Time) {
+
+	for id, lockout := range lockouts {
+		if now.Sub(lockout.LastFailure) > LoginFailureWindow && now.After(lockout.RetryAfter) {
+			delete(lockouts, id)
+		}
+	}
+	if len(lockouts) < maxLockouts {
+		return
+	}
+	list := make([]*Lockout, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		list = append(list, lockout)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastFailure.Before(list[j].LastFailure)
+	})
+	// keep some room, so that not every failed login has to sort the list
+	for _, lockout := range list[:len(list)-maxLockouts*9/10] {
+		delete(lockouts, lockout.ID)
+	}
+	log.Printf("[WARN ] Too many failed logins, forgot the oldest %d lockouts.", len(list)-len(lockouts))
+}
+
+// DeleteLockout clears the failed logins of that lockout ID.
+func DeleteLockout(id string) error {
+
+	lockoutsMutex.Lock()
+	defer lockoutsMutex.Unlock()
+
+	if _, ok := lockouts[id]; !ok {
+		return errLockoutNotFound
+	}
+	delete(lockouts, id)
+	return nil
+}
+
+// DeleteLockouts clears all failed logins.
+func DeleteLockouts() int {
+
+	lockoutsMutex.Lock()
+	defer lockoutsMutex.Unlock()
+
+	n := len(lockouts)
+	lockouts = map[string]*Lockout{}
+	return n
+}
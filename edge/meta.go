@@ -1,8 +1,10 @@
 package edge
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
+	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -44,6 +46,90 @@ func (meta Meta) SyncInterval() time.Duration {
 	return DefaultInterval
 }
 
+// ExpectedInterval = max time between two uplinks before the device is considered offline.
+// A zero duration means that the device is not watched.
+func (meta Meta) ExpectedInterval() time.Duration {
+	if meta != nil {
+		if m := meta["expectedInterval"]; m != nil {
+			switch i := m.(type) {
+			case string:
+				j, err := parseDuration(i)
+				if err != nil {
+					log.Printf("[ERR  ] Meta 'expectedInterval': %v", err)
+					return 0
+				}
+				return j
+			}
+		}
+	}
+	return 0
+}
+
+// CommandTTL = max time an actuator command may stay pending before it expires.
+func (meta Meta) CommandTTL() time.Duration {
+	if meta != nil {
+		if m := meta["commandTTL"]; m != nil {
+			switch i := m.(type) {
+			case string:
+				j, err := parseDuration(i)
+				if err != nil {
+					log.Printf("[ERR  ] Meta 'commandTTL': %v", err)
+					return DefaultCommandTTL
+				}
+				return j
+			}
+		}
+	}
+	return DefaultCommandTTL
+}
+
+// CodecRoute selects a codec for requests with that LoRaWAN fPort or header value.
+type CodecRoute struct {
+	FPort  int    `json:"fPort,omitempty"`
+	Header string `json:"header,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Codec  string `json:"codec"`
+}
+
+// CodecRoutes = list of codec routes, see RouteCodec.
+func (meta Meta) CodecRoutes() []CodecRoute {
+	if meta != nil {
+		if m := meta["codecs"]; m != nil {
+			var routes []CodecRoute
+			data, _ := json.Marshal(m)
+			if err := json.Unmarshal(data, &routes); err != nil {
+				log.Printf("[ERR  ] Meta 'codecs': %v", err)
+				return nil
+			}
+			return routes
+		}
+	}
+	return nil
+}
+
+// RouteCodec returns the codec of the first route that matches the headers, or "".
+// A route matches if its fPort equals the 'X-LoRaWAN-FPort' header
+// and its header (if any) has that value.
+func (meta Meta) RouteCodec(headers http.Header) string {
+	if headers == nil {
+		return ""
+	}
+	fPort, _ := strconv.Atoi(headers.Get("X-LoRaWAN-FPort"))
+	for _, route := range meta.CodecRoutes() {
+		if route.Codec == "" || (route.FPort == 0 && route.Header == "") {
+			continue
+		}
+		if route.FPort != 0 && route.FPort != fPort {
+			continue
+		}
+		if route.Header != "" && headers.Get(route.Header) != route.Value {
+			continue
+		}
+		return route.Codec
+	}
+	return ""
+}
+
 // DoNotSync = do not sync with clouds
 func (meta Meta) DoNotSync() bool {
 	if meta != nil {
@@ -63,6 +149,11 @@ var durationRegex = regexp.MustCompile(`^\s*([\d\.]+Y)?\s*([\d\.]+M)?\s*([\d\.]+
 
 var errFormat = errors.New("invalid time duration format")
 
+// ParseDuration parses durations like "15m", "1h30m" or "2D".
+func ParseDuration(str string) (time.Duration, error) {
+	return parseDuration(str)
+}
+
 func parseDuration(str string) (time.Duration, error) {
 	matches := durationRegex.FindStringSubmatch(str)
 	if matches == nil {
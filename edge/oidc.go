@@ -0,0 +1,210 @@
+package edge
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ProviderOIDC is the User.Provider of users that log in with OpenID Connect.
+const ProviderOIDC = "oidc"
+
+// OIDCConfig configures the login with an OpenID Connect identity provider.
+type OIDCConfig struct {
+	Enabled bool `json:"enabled"`
+	// Name is shown on the login button, like "Company Login".
+	Name string `json:"name"`
+	// Issuer is the URL of the identity provider, like "https://accounts.example.com".
+	// The configuration is read from {issuer}/.well-known/openid-configuration.
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// RedirectURL is the URL of /auth/oidc/callback as registered at the identity provider.
+	// If empty, it is derived from the request.
+	RedirectURL string `json:"redirectUrl"`
+	// Scopes are requested in addition to "openid".
+	Scopes []string `json:"scopes"`
+	// UsernameClaim is the claim for the username, "preferred_username" by default.
+	UsernameClaim string `json:"usernameClaim"`
+	// RoleClaim is the claim (string or list) that is mapped to roles, like "groups".
+	RoleClaim string `json:"roleClaim"`
+	// RoleMapping maps values of the RoleClaim to roles, like {"gateway-admins": "admin"}.
+	// If several values match, the role with most permissions is used.
+	RoleMapping map[string]string `json:"roleMapping"`
+	// DefaultRole is the role of users without matching RoleClaim.
+	// Empty to deny the login for such users.
+	DefaultRole string `json:"defaultRole"`
+}
+
+// oidcConfigKey is the config key to persist the OIDC configuration.
+const oidcConfigKey = "oidc"
+
+var oidcConfig *OIDCConfig
+var oidcConfigMutex sync.RWMutex
+
+// GetOIDCConfig returns the OIDC configuration. OIDC is disabled if none has been set.
+func GetOIDCConfig() (*OIDCConfig, error) {
+
+	oidcConfigMutex.RLock()
+	config := oidcConfig
+	oidcConfigMutex.RUnlock()
+	if config != nil {
+		return config, nil
+	}
+
+	config = new(OIDCConfig)
+	value, err := GetConfig(oidcConfigKey)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			return nil, CodeError{500, "database error: " + err.Error()}
+		}
+	} else if err := json.Unmarshal([]byte(value), config); err != nil {
+		return nil, CodeError{500, "invalid oidc config: " + err.Error()}
+	}
+	config.setDefaults()
+
+	oidcConfigMutex.Lock()
+	oidcConfig = config
+	oidcConfigMutex.Unlock()
+	return config, nil
+}
+
+// SetOIDCConfig validates and saves the OIDC configuration.
+// An empty client secret keeps the current secret.
+func SetOIDCConfig(config *OIDCConfig) error {
+
+	config.setDefaults()
+	if config.Enabled {
+		u, err := url.Parse(config.Issuer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return CodeError{400, "invalid issuer: must be a http(s) url"}
+		}
+		if config.ClientID == "" {
+			return CodeError{400, "the client id must not be empty"}
+		}
+	}
+	for value, role := range config.RoleMapping {
+		if !IsRole(role) {
+			return CodeError{400, "unknown role for " + value + ": " + role}
+		}
+	}
+	if config.DefaultRole != "" && !IsRole(config.DefaultRole) {
+		return CodeError{400, "unknown default role: " + config.DefaultRole}
+	}
+	if config.ClientSecret == "" {
+		if old, err := GetOIDCConfig(); err == nil {
+			config.ClientSecret = old.ClientSecret
+		}
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return CodeError{500, "can not marshal oidc config: " + err.Error()}
+	}
+	if err := SetConfig(oidcConfigKey, string(data)); err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+
+	oidcConfigMutex.Lock()
+	oidcConfig = config
+	oidcConfigMutex.Unlock()
+	return nil
+}
+
+func (config *OIDCConfig) setDefaults() {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if config.Name == "" {
+		config.Name = "OpenID Connect"
+	}
+	if config.Scopes == nil {
+		config.Scopes = []string{"profile", "email"}
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	if config.RoleMapping == nil {
+		config.RoleMapping = map[string]string{}
+	}
+}
+
+// MapRole returns the role for the values of the role claim, or the default role.
+func (config *OIDCConfig) MapRole(values []string) string {
+	for _, role := range Roles() {
+		for _, value := range values {
+			if config.RoleMapping[value] == role {
+				return role
+			}
+		}
+	}
+	return config.DefaultRole
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// UpsertOIDCUser returns the user of that OIDC subject and updates the name and role,
+// or creates a new user if this is the first login.
+// Local users with the same username are not taken over.
+func UpsertOIDCUser(subject string, username string, name string, role string) (*User, error) {
+
+	// users without role are admins (see EffectiveRole), so the role is required
+	if !IsRole(role) {
+		return nil, CodeError{400, "unknown role: " + role}
+	}
+
+	var user User
+	err := dbUsers.Find(bson.M{
+		"provider": ProviderOIDC,
+		"subject":  subject,
+	}).One(&user)
+	if err == nil {
+		err = dbUsers.UpdateId(user.ID, bson.M{"$set": bson.M{
+			"name": name,
+			"role": role,
+		}})
+		if err != nil {
+			return nil, CodeError{500, "database error: " + err.Error()}
+		}
+		user.Name = name
+		user.Role = role
+		return &user, nil
+	}
+	if err != mgo.ErrNotFound {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+
+	username = strings.ToLower(username)
+	if _, err := FindUserByUsername(username); err == nil {
+		return nil, CodeError{409, "a local user " + username + " exists already"}
+	}
+
+	// OIDC users have no (known) password, they can not log in with POST /auth/token
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, CodeError{500, "can not create user: " + err.Error()}
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(password)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, CodeError{500, "can not create user: " + err.Error()}
+	}
+
+	user = User{
+		ID:       bson.NewObjectId().Hex(),
+		Name:     name,
+		Username: username,
+		Password: string(hashedPassword),
+		Role:     role,
+		Provider: ProviderOIDC,
+		Subject:  subject,
+	}
+	if err := dbUsers.Insert(&user); err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &user, nil
+}
@@ -43,6 +43,16 @@ func (k *SensingKind) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ParseSensingKind returns the sensing kind with that name.
+func ParseSensingKind(str string) (SensingKind, bool) {
+	for i, s := range sensingKindStr {
+		if s == str {
+			return SensingKind(i), true
+		}
+	}
+	return 0, false
+}
+
 var sensingKindStr = []string{
 	"",
 	"Accelerometer",
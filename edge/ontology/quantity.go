@@ -41,6 +41,16 @@ func (q *Quantity) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ParseQuantity returns the quantity with that name.
+func ParseQuantity(str string) (Quantity, bool) {
+	for i, s := range quantityStr {
+		if s == str {
+			return Quantity(i), true
+		}
+	}
+	return 0, false
+}
+
 var quantityStr = []string{
 	"",
 	"Acceleration",
@@ -41,6 +41,16 @@ func (u *Unit) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ParseUnit returns the unit with that name.
+func ParseUnit(str string) (Unit, bool) {
+	for i, s := range unitStr {
+		if s == str {
+			return Unit(i), true
+		}
+	}
+	return 0, false
+}
+
 var unitStr = []string{
 	"",
 	"Ampere",
@@ -0,0 +1,83 @@
+package edge
+
+import (
+	"strings"
+)
+
+// User roles.
+const (
+	// RoleAdmin can do everything, including user management.
+	RoleAdmin = "admin"
+	// RoleOperator manages devices, codecs, apps and clouds, but not users or the system.
+	RoleOperator = "operator"
+	// RoleViewer can read everything except users.
+	RoleViewer = "viewer"
+	// RoleDeviceWriter can read and write devices, sensors and actuators only.
+	RoleDeviceWriter = "device-writer"
+)
+
+// Permissions are "{resource}:{action}" with the actions "read" and "write".
+// "*" matches any resource or action.
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		"*:*",
+	},
+	RoleOperator: {
+		"devices:*",
+		"codecs:*",
+		"apps:*",
+		"clouds:*",
+		"sys:read",
+	},
+	RoleViewer: {
+		"devices:read",
+		"codecs:read",
+		"apps:read",
+		"clouds:read",
+		"sys:read",
+	},
+	RoleDeviceWriter: {
+		"devices:*",
+	},
+}
+
+// Roles returns all known roles.
+func Roles() []string {
+	return []string{RoleAdmin, RoleOperator, RoleViewer, RoleDeviceWriter}
+}
+
+// IsRole checks if the role is known.
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permissions returns the permissions of that role.
+func Permissions(role string) []string {
+	return append([]string(nil), rolePermissions[role]...)
+}
+
+// HasPermission checks if the role grants that permission, like "devices:write".
+func HasPermission(role string, permission string) bool {
+	return MatchPermission(rolePermissions[role], permission)
+}
+
+// MatchPermission checks if any of the granted permissions (which may contain "*") matches the permission.
+func MatchPermission(granted []string, permission string) bool {
+	resource, action := splitPermission(permission)
+	for _, p := range granted {
+		r, a := splitPermission(p)
+		if (r == "*" || r == resource) && (a == "*" || a == action) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPermission(permission string) (resource string, action string) {
+	i := strings.IndexByte(permission, ':')
+	if i == -1 {
+		return permission, "*"
+	}
+	return permission[:i], permission[i+1:]
+}
@@ -0,0 +1,59 @@
+package edge
+
+import "testing"
+
+func TestMatchPermission(t *testing.T) {
+
+	tests := []struct {
+		granted    []string
+		permission string
+		match      bool
+	}{
+		{[]string{"*:*"}, "users:write", true},
+		{[]string{"devices:*"}, "devices:write", true},
+		{[]string{"devices:*"}, "codecs:read", false},
+		{[]string{"*:read"}, "sys:read", true},
+		{[]string{"*:read"}, "sys:write", false},
+		{[]string{"devices:read", "codecs:write"}, "codecs:write", true},
+		{[]string{"devices:read"}, "devices:write", false},
+		// a permission without action is any action
+		{[]string{"devices"}, "devices:write", true},
+		{[]string{"devices:read"}, "devices", false},
+		{[]string{"devices:*"}, "devices", true},
+		{nil, "devices:read", false},
+	}
+
+	for _, test := range tests {
+		if m := MatchPermission(test.granted, test.permission); m != test.match {
+			t.Errorf("%v %s: got %v, want %v", test.granted, test.permission, m, test.match)
+		}
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+
+	tests := []struct {
+		role       string
+		permission string
+		has        bool
+	}{
+		{RoleAdmin, "users:write", true},
+		{RoleOperator, "codecs:write", true},
+		{RoleOperator, "sys:read", true},
+		{RoleOperator, "sys:write", false},
+		{RoleOperator, "users:read", false},
+		{RoleViewer, "clouds:read", true},
+		{RoleViewer, "devices:write", false},
+		{RoleViewer, "users:read", false},
+		{RoleDeviceWriter, "devices:write", true},
+		{RoleDeviceWriter, "codecs:read", false},
+		{"unknown", "devices:read", false},
+		{"", "devices:read", false},
+	}
+
+	for _, test := range tests {
+		if h := HasPermission(test.role, test.permission); h != test.has {
+			t.Errorf("%s %s: got %v, want %v", test.role, test.permission, h, test.has)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package edge
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// A scratch device lives in memory only. Codecs that run with TestCodec
+// write to a scratch device, so nothing is persisted in the database.
+// The scratch implements DeviceStore for that one device.
+type scratch struct {
+	device   *Device
+	warnings []string
+	errors   []string
+	logs     []string
+	commands []CommandReport
+}
+
+// CodecTestResult is the result of a codec test run.
+type CodecTestResult struct {
+	Sensors   []*Sensor       `json:"sensors"`
+	Actuators []*Actuator     `json:"actuators"`
+	Commands  []CommandReport `json:"commands"`
+	Warnings  []string        `json:"warnings"`
+	Errors    []string        `json:"errors"`
+	Logs      []string        `json:"logs"`
+}
+
+// TestCodec runs the codec with that payload against a scratch copy of the device.
+// The device may be nil or list sensors and actuators that exist before the test.
+// Errors of the codec are returned in the result.
+func TestCodec(codec Codec, device *Device, headers http.Header, payload []byte) *CodecTestResult {
+
+	s := &scratch{
+		device: &Device{},
+	}
+	if device != nil {
+		*s.device = *device
+	}
+	s.device.ID = "scratch-" + bson.NewObjectId().Hex()
+	if s.device.Name == "" {
+		s.device.Name = "Scratch Device"
+	}
+
+	err := codec.UnmarshalDevice(s, s.device.ID, headers, bytes.NewReader(payload))
+
+	result := &CodecTestResult{
+		Sensors:   s.device.Sensors,
+		Actuators: s.device.Actuators,
+		Commands:  s.commands,
+		Warnings:  s.warnings,
+		Errors:    s.errors,
+		Logs:      s.logs,
+	}
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	if result.Sensors == nil {
+		result.Sensors = []*Sensor{}
+	}
+	if result.Actuators == nil {
+		result.Actuators = []*Actuator{}
+	}
+	if result.Commands == nil {
+		result.Commands = []CommandReport{}
+	}
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	if result.Errors == nil {
+		result.Errors = []string{}
+	}
+	if result.Logs == nil {
+		result.Logs = []string{}
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (s *scratch) GetDevice(deviceID string) (*Device, error) {
+	if deviceID != s.device.ID {
+		return nil, ErrNotFound
+	}
+	device := *s.device
+	device.Sensors = append([]*Sensor(nil), s.device.Sensors...)
+	device.Actuators = append([]*Actuator(nil), s.device.Actuators...)
+	return &device, nil
+}
+
+func (s *scratch) PostSensor(deviceID string, sensor *Sensor) error {
+	if deviceID != s.device.ID {
+		return ErrNotFound
+	}
+	if sensor.ID == "" {
+		sensor.ID = bson.NewObjectId().Hex()
+	}
+	for _, sensor2 := range s.device.Sensors {
+		if sensor2.ID == sensor.ID {
+			return CodeError{409, "sensor already exists"}
+		}
+	}
+	now := time.Now()
+	sensor.Modified = now
+	sensor.Created = now
+	if sensor.Value == nil {
+		sensor.Time = nil
+	} else if sensor.Time == nil {
+		sensor.Time = &now
+	}
+	s.device.Sensors = append(s.device.Sensors, sensor)
+	return nil
+}
+
+func (s *scratch) getSensor(deviceID string, sensorID string) *Sensor {
+	if deviceID != s.device.ID {
+		return nil
+	}
+	for _, sensor := range s.device.Sensors {
+		if sensor.ID == sensorID {
+			return sensor
+		}
+	}
+	return nil
+}
+
+func (s *scratch) PostSensorValue(deviceID string, sensorID string, val Value) (Meta, error) {
+	sensor := s.getSensor(deviceID, sensorID)
+	if sensor == nil {
+		return nil, ErrNotFound
+	}
+	t := val.Time
+	sensor.Value = val.Value
+	sensor.Time = &t
+	return sensor.Meta, nil
+}
+
+func (s *scratch) PostSensorValues(deviceID string, sensorID string, vals []Value) (Meta, error) {
+	if len(vals) == 0 {
+		return nil, errNoValues
+	}
+	return s.PostSensorValue(deviceID, sensorID, vals[len(vals)-1])
+}
+
+func (s *scratch) SetSensorMeta(deviceID string, sensorID string, meta Meta) error {
+	sensor := s.getSensor(deviceID, sensorID)
+	if sensor == nil {
+		return ErrNotFound
+	}
+	if sensor.Meta == nil {
+		sensor.Meta = Meta{}
+	}
+	for key, value := range meta {
+		if value == nil {
+			delete(sensor.Meta, key)
+		} else {
+			sensor.Meta[key] = value
+		}
+	}
+	return nil
+}
+
+func (s *scratch) PostActuator(deviceID string, actuator *Actuator) error {
+	if deviceID != s.device.ID {
+		return ErrNotFound
+	}
+	if actuator.ID == "" {
+		actuator.ID = bson.NewObjectId().Hex()
+	}
+	for _, actuator2 := range s.device.Actuators {
+		if actuator2.ID == actuator.ID {
+			return CodeError{409, "actuator already exists"}
+		}
+	}
+	now := time.Now()
+	actuator.Modified = now
+	actuator.Created = now
+	if actuator.Value == nil {
+		actuator.Time = nil
+	} else if actuator.Time == nil {
+		actuator.Time = &now
+	}
+	s.device.Actuators = append(s.device.Actuators, actuator)
+	return nil
+}
+
+// ReportCommands records the reports of the codec. There are no pending commands to change.
+func (s *scratch) ReportCommands(deviceID string, reports []CommandReport) {
+	for _, report := range reports {
+		if report.State != CommandSent && report.State != CommandAcknowledged && report.State != CommandFailed {
+			s.errors = append(s.errors, "command report: "+errBadCommandState.Text)
+			continue
+		}
+		s.commands = append(s.commands, report)
+	}
+}
+
+func (s *scratch) Report(deviceID string, warnings []string, errors []string, logs []string) {
+	s.warnings = append(s.warnings, warnings...)
+	s.errors = append(s.errors, errors...)
+	s.logs = append(s.logs, logs...)
+}
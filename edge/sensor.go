@@ -94,7 +94,6 @@ func GetSensor(deviceID string, sensorID string) (*Sensor, error) {
 
 // PostSensor creates a new sensor for this device.
 func PostSensor(deviceID string, sensor *Sensor) error {
-
 	if sensor.ID == "" {
 		sensor.ID = bson.NewObjectId().Hex()
 	}
@@ -193,7 +192,6 @@ func SetSensorName(deviceID string, sensorID string, name string) (Meta, error)
 
 // SetSensorMeta changes this sensors metadata.
 func SetSensorMeta(deviceID string, sensorID string, meta Meta) error {
-
 	var unset = bson.M{}
 	var set = bson.M{
 		"sensors.$.modified": time.Now(),
@@ -353,7 +351,6 @@ type sValue struct {
 
 // PostSensorValue stores a new sensor value for this sensor.
 func PostSensorValue(deviceID string, sensorID string, val Value) (Meta, error) {
-
 	value := sValue{
 		ID:       newID(val.Time),
 		Value:    val.Value,
@@ -375,6 +372,7 @@ func PostSensorValue(deviceID string, sensorID string, val Value) (Meta, error)
 			"$set": bson.M{
 				"sensors.$.value": val.Value,
 				"sensors.$.time":  val.Time,
+				"lastSeen":        time.Now(),
 			},
 		},
 	}, &device)
@@ -405,8 +403,13 @@ func PostSensorValue(deviceID string, sensorID string, val Value) (Meta, error)
 	return nil, nil
 }
 
+var errNoValues = CodeError{400, "no values"}
+
 // PostSensorValues can be used to post multiple data point for this sensor.
 func PostSensorValues(deviceID string, sensorID string, vals []Value) (Meta, error) {
+	if len(vals) == 0 {
+		return nil, errNoValues
+	}
 
 	values := make([]sValue, len(vals))
 	interf := make([]interface{}, len(vals))
@@ -436,6 +439,7 @@ func PostSensorValues(deviceID string, sensorID string, vals []Value) (Meta, err
 			"$set": bson.M{
 				"sensors.$.value": val.Value,
 				"sensors.$.time":  val.Time,
+				"lastSeen":        time.Now(),
 			},
 		},
 	}, &device)
@@ -0,0 +1,289 @@
+package edge
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// SigningKeyRotation is the time after which a new JWT signing key is created.
+const SigningKeyRotation = 30 * 24 * time.Hour
+
+// SigningKeyGrace is the time that a rotated signing key is still accepted,
+// so that tokens signed before the rotation stay valid until they expire.
+const SigningKeyGrace = 24 * time.Hour
+
+// SessionLifetime is the time after which a session expires if its token is not refreshed.
+const SessionLifetime = 24 * time.Hour
+
+// SigningKey is a HMAC secret to sign JWT tokens with.
+// The ID is used as the 'kid' header of the tokens.
+type SigningKey struct {
+	ID      string    `json:"id"`
+	Secret  []byte    `json:"secret"`
+	Created time.Time `json:"created"`
+	// Rotated is the time when a newer key replaced this key.
+	Rotated *time.Time `json:"rotated,omitempty"`
+}
+
+// signingKeysConfig is the config key to persist the signing keys.
+const signingKeysConfig = "jwtSigningKeys"
+
+var signingKeys []*SigningKey
+var signingKeysMutex sync.RWMutex
+
+func newSigningKey() (*SigningKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:      bson.NewObjectId().Hex(),
+		Secret:  secret,
+		Created: time.Now(),
+	}, nil
+}
+
+func loadSigningKeys() ([]*SigningKey, error) {
+	value, err := GetConfig(signingKeysConfig)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var keys []*SigningKey
+	err = json.Unmarshal([]byte(value), &keys)
+	return keys, err
+}
+
+func saveSigningKeys(keys []*SigningKey) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return SetConfig(signingKeysConfig, string(data))
+}
+
+// RotateSigningKeys loads the signing keys from the config store,
+// creates a new key if there is none or the current key is older than SigningKeyRotation
+// and drops keys that have been rotated for longer than SigningKeyGrace.
+func RotateSigningKeys() error {
+
+	signingKeysMutex.Lock()
+	defer signingKeysMutex.Unlock()
+
+	keys, err := loadSigningKeys()
+	if err != nil {
+		return CodeError{500, "can not load signing keys: " + err.Error()}
+	}
+
+	now := time.Now()
+	changed := false
+	if len(keys) == 0 || now.Sub(keys[len(keys)-1].Created) > SigningKeyRotation {
+		key, err := newSigningKey()
+		if err != nil {
+			return CodeError{500, "can not create signing key: " + err.Error()}
+		}
+		if len(keys) != 0 {
+			keys[len(keys)-1].Rotated = &now
+		}
+		keys = append(keys, key)
+		changed = true
+	}
+
+	valid := keys[:0]
+	for _, key := range keys {
+		if key.Rotated != nil && now.Sub(*key.Rotated) > SigningKeyGrace {
+			changed = true
+			continue
+		}
+		valid = append(valid, key)
+	}
+	keys = valid
+
+	if changed {
+		if err := saveSigningKeys(keys); err != nil {
+			return CodeError{500, "can not save signing keys: " + err.Error()}
+		}
+		log.Printf("[INFO ] JWT signing key %q is active.", keys[len(keys)-1].ID)
+	}
+	signingKeys = keys
+	return nil
+}
+
+// CurrentSigningKey returns the key to sign new tokens with.
+func CurrentSigningKey() (*SigningKey, error) {
+	signingKeysMutex.RLock()
+	n := len(signingKeys)
+	signingKeysMutex.RUnlock()
+	if n == 0 {
+		if err := RotateSigningKeys(); err != nil {
+			return nil, err
+		}
+	}
+	signingKeysMutex.RLock()
+	defer signingKeysMutex.RUnlock()
+	return signingKeys[len(signingKeys)-1], nil
+}
+
+// GetSigningKey returns the (current or rotated) signing key with that ID.
+func GetSigningKey(keyID string) *SigningKey {
+	signingKeysMutex.RLock()
+	defer signingKeysMutex.RUnlock()
+	for _, key := range signingKeys {
+		if key.ID == keyID {
+			return key
+		}
+	}
+	return nil
+}
+
+// SigningKeysRotator rotates the signing keys every hour.
+// It does not return.
+func SigningKeysRotator() {
+	for range time.Tick(time.Hour) {
+		if err := RotateSigningKeys(); err != nil {
+			log.Printf("[ERR  ] Rotating signing keys: %v", err)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Session is a login of a user. Every token belongs to a session,
+// and tokens of a revoked (deleted) session are no longer valid.
+type Session struct {
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	Created   time.Time `json:"created" bson:"created"`
+	LastSeen  time.Time `json:"lastSeen" bson:"lastSeen"`
+	Expires   time.Time `json:"expires" bson:"expires"`
+	Addr      string    `json:"addr" bson:"addr"`
+	UserAgent string    `json:"userAgent" bson:"userAgent"`
+}
+
+var errSessionNotFound = CodeError{404, "session not found"}
+
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// PostSession creates a new session for the user.
+func PostSession(session *Session) error {
+
+	id, err := newSessionID()
+	if err != nil {
+		return CodeError{500, "can not create session: " + err.Error()}
+	}
+	now := time.Now()
+	session.ID = id
+	session.Created = now
+	session.LastSeen = now
+	session.Expires = now.Add(SessionLifetime)
+	if err := dbSessions.Insert(session); err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// CheckSession returns the session if it exists and has not expired.
+func CheckSession(sessionID string) (*Session, error) {
+
+	var session Session
+	err := dbSessions.FindId(sessionID).One(&session)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, CodeError{401, "session revoked or expired"}
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	if time.Now().After(session.Expires) {
+		return nil, CodeError{401, "session revoked or expired"}
+	}
+	return &session, nil
+}
+
+// RefreshSession extends the lifetime of the session.
+func RefreshSession(sessionID string) error {
+
+	now := time.Now()
+	err := dbSessions.UpdateId(sessionID, bson.M{
+		"$set": bson.M{
+			"lastSeen": now,
+			"expires":  now.Add(SessionLifetime),
+		},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errSessionNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// GetSessions returns the active sessions of that user.
+func GetSessions(userID string) ([]Session, error) {
+
+	sessions := []Session{}
+	err := dbSessions.Find(bson.M{
+		"userId":  userID,
+		"expires": bson.M{"$gt": time.Now()},
+	}).Sort("created").All(&sessions)
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return sessions, nil
+}
+
+// DeleteSession revokes the session of that user.
+func DeleteSession(userID string, sessionID string) error {
+
+	err := dbSessions.Remove(bson.M{
+		"_id":    sessionID,
+		"userId": userID,
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errSessionNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// DeleteOtherSessions revokes all sessions of that user except the session with that ID.
+func DeleteOtherSessions(userID string, sessionID string) (int, error) {
+
+	info, err := dbSessions.RemoveAll(bson.M{
+		"userId": userID,
+		"_id":    bson.M{"$ne": sessionID},
+	})
+	if err != nil {
+		return 0, CodeError{500, "database error: " + err.Error()}
+	}
+	return info.Removed, nil
+}
+
+// DeleteSessions revokes all sessions of that user.
+func DeleteSessions(userID string) (int, error) {
+
+	info, err := dbSessions.RemoveAll(bson.M{
+		"userId": userID,
+	})
+	if err != nil {
+		return 0, CodeError{500, "database error: " + err.Error()}
+	}
+	return info.Removed, nil
+}
@@ -0,0 +1,63 @@
+package edge
+
+import "log"
+
+// A DeviceStore is where codecs read devices from and write sensor values to.
+// Codecs get the store as an argument: DB for real uplinks and downlinks,
+// an in-memory scratch device for TestCodec.
+type DeviceStore interface {
+	GetDevice(deviceID string) (*Device, error)
+	PostSensor(deviceID string, sensor *Sensor) error
+	PostSensorValue(deviceID string, sensorID string, val Value) (Meta, error)
+	PostSensorValues(deviceID string, sensorID string, vals []Value) (Meta, error)
+	SetSensorMeta(deviceID string, sensorID string, meta Meta) error
+	PostActuator(deviceID string, actuator *Actuator) error
+	ReportCommands(deviceID string, reports []CommandReport)
+	// Report adds the warnings, errors and logs of a codec run.
+	Report(deviceID string, warnings []string, errors []string, logs []string)
+}
+
+// DB is the DeviceStore of the database.
+var DB DeviceStore = dbStore{}
+
+type dbStore struct{}
+
+func (dbStore) GetDevice(deviceID string) (*Device, error) {
+	return GetDevice(deviceID)
+}
+
+func (dbStore) PostSensor(deviceID string, sensor *Sensor) error {
+	return PostSensor(deviceID, sensor)
+}
+
+func (dbStore) PostSensorValue(deviceID string, sensorID string, val Value) (Meta, error) {
+	return PostSensorValue(deviceID, sensorID, val)
+}
+
+func (dbStore) PostSensorValues(deviceID string, sensorID string, vals []Value) (Meta, error) {
+	return PostSensorValues(deviceID, sensorID, vals)
+}
+
+func (dbStore) SetSensorMeta(deviceID string, sensorID string, meta Meta) error {
+	return SetSensorMeta(deviceID, sensorID, meta)
+}
+
+func (dbStore) PostActuator(deviceID string, actuator *Actuator) error {
+	return PostActuator(deviceID, actuator)
+}
+
+func (dbStore) ReportCommands(deviceID string, reports []CommandReport) {
+	ReportCommands(deviceID, reports)
+}
+
+// Report writes the warnings and errors of the codec to the gateway log.
+// The script logs (console.log) are left out: scripts may log on every uplink,
+// and they can be seen with the codec test (POST /codecs/:codec_id/test) instead.
+func (dbStore) Report(deviceID string, warnings []string, errors []string, logs []string) {
+	for _, warning := range warnings {
+		log.Printf("[WARN ] Codec of device %q: %s", deviceID, warning)
+	}
+	for _, err := range errors {
+		log.Printf("[ERR  ] Codec of device %q: %s", deviceID, err)
+	}
+}
@@ -0,0 +1,184 @@
+package edge
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Waziup/wazigate-edge/edge/ontology"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Tag is a group of devices that share the same tag.
+type Tag struct {
+	Name    string `json:"name" bson:"_id"`
+	Devices int    `json:"devices" bson:"devices"`
+}
+
+// TagValue is the latest value of one sensor in a group of devices.
+type TagValue struct {
+	DeviceID   string               `json:"deviceId"`
+	DeviceName string               `json:"deviceName"`
+	SensorID   string               `json:"sensorId"`
+	SensorName string               `json:"sensorName"`
+	Kind       ontology.SensingKind `json:"kind"`
+	Quantity   ontology.Quantity    `json:"quantity"`
+	Unit       ontology.Unit        `json:"unit"`
+	Time       *time.Time           `json:"time"`
+	Value      interface{}          `json:"value"`
+}
+
+// cleanTags removes empty and duplicate tags.
+func cleanTags(tags []string) []string {
+	clean := make([]string, 0, len(tags))
+TAGS:
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		for _, t := range clean {
+			if t == tag {
+				continue TAGS
+			}
+		}
+		clean = append(clean, tag)
+	}
+	return clean
+}
+
+// SetDeviceTags replaces the tags of that device.
+func SetDeviceTags(deviceID string, tags []string) error {
+
+	err := dbDevices.UpdateId(deviceID, bson.M{
+		"$set": bson.M{
+			"modified": time.Now(),
+			"tags":     cleanTags(tags),
+		},
+	})
+
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return ErrNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// GetTags returns all tags and the number of devices in each group.
+func GetTags() ([]Tag, error) {
+
+	tags := []Tag{}
+	err := dbDevices.Pipe([]bson.M{
+		{"$unwind": "$tags"},
+		{"$group": bson.M{
+			"_id":     "$tags",
+			"devices": bson.M{"$sum": 1},
+		}},
+	}).All(&tags)
+
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// GetTagDeviceIDs returns the IDs of all devices with that tag.
+func GetTagDeviceIDs(tag string) ([]string, error) {
+
+	var devices []Device
+	err := dbDevices.Find(bson.M{
+		"tags": tag,
+	}).Select(bson.M{
+		"_id": 1,
+	}).All(&devices)
+
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	ids := make([]string, len(devices))
+	for i, device := range devices {
+		ids[i] = device.ID
+	}
+	return ids, nil
+}
+
+// SetTagMeta changes the metadata of all devices with that tag.
+// This returns the IDs of all devices that have been changed.
+func SetTagMeta(tag string, meta Meta) ([]string, error) {
+
+	ids, err := GetTagDeviceIDs(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	var unset = bson.M{}
+	var set = bson.M{
+		"modified": time.Now(),
+	}
+	for key, value := range meta {
+		if value == nil {
+			unset["meta."+key] = 1
+		} else {
+			set["meta."+key] = value
+		}
+	}
+
+	m := bson.M{
+		"$set": set,
+	}
+	if len(unset) != 0 {
+		m["$unset"] = unset
+	}
+
+	_, err = dbDevices.UpdateAll(bson.M{
+		"_id": bson.M{"$in": ids},
+	}, m)
+
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return ids, nil
+}
+
+// GetTagValues returns the latest values of all sensors of all devices with that tag.
+func GetTagValues(tag string) ([]TagValue, error) {
+
+	var devices []Device
+	err := dbDevices.Find(bson.M{
+		"tags": tag,
+	}).Select(bson.M{
+		"name":    1,
+		"sensors": 1,
+	}).Sort("_id").All(&devices)
+
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+
+	values := []TagValue{}
+	for _, device := range devices {
+		for _, sensor := range device.Sensors {
+			values = append(values, TagValue{
+				DeviceID:   device.ID,
+				DeviceName: device.Name,
+				SensorID:   sensor.ID,
+				SensorName: sensor.Name,
+				Kind:       sensor.Kind,
+				Quantity:   sensor.Quantity,
+				Unit:       sensor.Unit,
+				Time:       sensor.Time,
+				Value:      sensor.Value,
+			})
+		}
+	}
+	return values, nil
+}
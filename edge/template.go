@@ -0,0 +1,164 @@
+package edge
+
+import (
+	"io"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// DeviceTemplate is a blueprint for devices with the same sensors and actuators.
+type DeviceTemplate struct {
+	ID        string      `json:"id" bson:"_id"`
+	Name      string      `json:"name" bson:"name"`
+	Sensors   []*Sensor   `json:"sensors" bson:"sensors"`
+	Actuators []*Actuator `json:"actuators" bson:"actuators"`
+	Tags      []string    `json:"tags" bson:"tags"`
+	Meta      Meta        `json:"meta" bson:"meta"`
+	Codec     string      `json:"codec" bson:"codec"`
+	Modified  time.Time   `json:"modified" bson:"modified"`
+	Created   time.Time   `json:"created" bson:"created"`
+}
+
+// NewDevice creates a new device from this template.
+// The device is not stored, use PostDevices to create it.
+func (tmpl *DeviceTemplate) NewDevice(deviceID string, name string) *Device {
+
+	device := &Device{
+		ID:        deviceID,
+		Name:      name,
+		Sensors:   make([]*Sensor, len(tmpl.Sensors)),
+		Actuators: make([]*Actuator, len(tmpl.Actuators)),
+		Tags:      append([]string(nil), tmpl.Tags...),
+		Meta:      copyMeta(tmpl.Meta),
+	}
+	if device.Name == "" {
+		device.Name = tmpl.Name
+	}
+	for i, sensor := range tmpl.Sensors {
+		s := *sensor
+		s.Meta = copyMeta(sensor.Meta)
+		device.Sensors[i] = &s
+	}
+	for i, actuator := range tmpl.Actuators {
+		a := *actuator
+		a.Meta = copyMeta(actuator.Meta)
+		device.Actuators[i] = &a
+	}
+	if device.Meta == nil {
+		device.Meta = Meta{}
+	}
+	if tmpl.Codec != "" {
+		device.Meta["codec"] = tmpl.Codec
+	}
+	device.Meta["template"] = tmpl.ID
+	return device
+}
+
+func copyMeta(meta Meta) Meta {
+	if meta == nil {
+		return nil
+	}
+	clone := make(Meta, len(meta))
+	for key, value := range meta {
+		clone[key] = value
+	}
+	return clone
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PostTemplate creates or replaces a device template.
+func PostTemplate(tmpl *DeviceTemplate) error {
+
+	now := time.Now()
+	if tmpl.ID == "" {
+		tmpl.ID = newID(now).Hex()
+		tmpl.Created = now
+	} else {
+		var old DeviceTemplate
+		err := dbTemplates.FindId(tmpl.ID).Select(bson.M{"created": 1}).One(&old)
+		if err == nil {
+			tmpl.Created = old.Created
+		} else if err == mgo.ErrNotFound {
+			tmpl.Created = now
+		} else {
+			return CodeError{500, "database error: " + err.Error()}
+		}
+	}
+	tmpl.Modified = now
+
+	for _, sensor := range tmpl.Sensors {
+		if sensor.ID == "" {
+			sensor.ID = bson.NewObjectId().Hex()
+		}
+	}
+	for _, actuator := range tmpl.Actuators {
+		if actuator.ID == "" {
+			actuator.ID = bson.NewObjectId().Hex()
+		}
+	}
+
+	_, err := dbTemplates.UpsertId(tmpl.ID, tmpl)
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// GetTemplate returns the device template with that id.
+func GetTemplate(templateID string) (*DeviceTemplate, error) {
+
+	var tmpl DeviceTemplate
+	err := dbTemplates.FindId(templateID).One(&tmpl)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, CodeError{404, "template not found"}
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return &tmpl, nil
+}
+
+// DeleteTemplate removes the device template.
+// Devices created from that template are not affected.
+func DeleteTemplate(templateID string) error {
+
+	err := dbTemplates.RemoveId(templateID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return CodeError{404, "template not found"}
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// TemplatesIter iterates over device templates. Call .Next() to get the next template.
+type TemplatesIter struct {
+	tmpl   DeviceTemplate
+	dbIter *mgo.Iter
+}
+
+// Next returns the next template or nil.
+func (iter *TemplatesIter) Next() (*DeviceTemplate, error) {
+	iter.tmpl = DeviceTemplate{}
+	if iter.dbIter.Next(&iter.tmpl) {
+		return &iter.tmpl, iter.dbIter.Err()
+	}
+	return nil, io.EOF
+}
+
+// Close closes the iterator.
+func (iter *TemplatesIter) Close() error {
+	return iter.dbIter.Close()
+}
+
+// GetTemplates returns an iterator over all device templates.
+func GetTemplates() *TemplatesIter {
+
+	return &TemplatesIter{
+		dbIter: dbTemplates.Find(nil).Iter(),
+	}
+}
@@ -0,0 +1,228 @@
+package edge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// TOTPIssuer is the issuer shown in authenticator apps.
+const TOTPIssuer = "Wazigate"
+
+// TOTP parameters (RFC 6238), the defaults of all common authenticator apps.
+const (
+	totpPeriod = 30 // seconds
+	totpDigits = 6
+	// totpSkew is the number of periods that codes may be early or late.
+	totpSkew = 1
+)
+
+// RecoveryCodesCount is the number of recovery codes created with the TOTP enrolment.
+const RecoveryCodesCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrolment is the secret of a new TOTP enrolment, to be added to an authenticator app.
+type TOTPEnrolment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// provisioning URI, to be shown as QR code.
+	URI string `json:"uri"`
+}
+
+// totpCode returns the code for the counter (HOTP, RFC 4226).
+func totpCode(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// BeginTOTP creates a new TOTP secret for the user. The enrolment is not active
+// until it has been confirmed with a code (see ConfirmTOTP).
+func BeginTOTP(userID string) (*TOTPEnrolment, error) {
+
+	user, err := GetUser(userID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, errUserNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	if user.TOTPEnabled {
+		return nil, CodeError{409, "TOTP is already enabled, disable it first"}
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, CodeError{500, "can not create secret: " + err.Error()}
+	}
+	encoded := totpEncoding.EncodeToString(secret)
+
+	err = dbUsers.UpdateId(userID, bson.M{"$set": bson.M{
+		"totpSecret":  encoded,
+		"totpCounter": 0,
+	}})
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+
+	label := url.PathEscape(TOTPIssuer + ":" + user.Username)
+	query := url.Values{}
+	query.Set("secret", encoded)
+	query.Set("issuer", TOTPIssuer)
+	query.Set("period", fmt.Sprint(totpPeriod))
+	query.Set("digits", fmt.Sprint(totpDigits))
+	return &TOTPEnrolment{
+		Secret: encoded,
+		URI:    "otpauth://totp/" + label + "?" + query.Encode(),
+	}, nil
+}
+
+// ConfirmTOTP enables TOTP for the user if the code matches the new secret.
+// It returns the recovery codes, which can be used once each instead of a TOTP code.
+func ConfirmTOTP(userID string, code string) ([]string, error) {
+
+	user, err := GetUser(userID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, errUserNotFound
+		}
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	if user.TOTPEnabled {
+		return nil, CodeError{409, "TOTP is already enabled"}
+	}
+	if user.TOTPSecret == "" {
+		return nil, CodeError{400, "no TOTP enrolment, start with POST /auth/totp"}
+	}
+	counter, ok := matchTOTP(user.TOTPSecret, code, 0)
+	if !ok {
+		return nil, CodeError{403, "invalid TOTP code"}
+	}
+
+	codes := make([]string, RecoveryCodesCount)
+	hashes := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, CodeError{500, "can not create recovery codes: " + err.Error()}
+		}
+		s := strings.ToLower(totpEncoding.EncodeToString(b))
+		codes[i] = s[:4] + "-" + s[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	err = dbUsers.UpdateId(userID, bson.M{"$set": bson.M{
+		"totpEnabled":   true,
+		"totpCounter":   counter,
+		"recoveryCodes": hashes,
+	}})
+	if err != nil {
+		return nil, CodeError{500, "database error: " + err.Error()}
+	}
+	return codes, nil
+}
+
+// CheckTOTP checks the TOTP code or recovery code of the user.
+// Every TOTP code and recovery code is accepted only once.
+func CheckTOTP(user *User, code string) error {
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return CodeError{401, "TOTP code required"}
+	}
+
+	if counter, ok := matchTOTP(user.TOTPSecret, code, user.TOTPCounter); ok {
+		// the counter makes sure that the code can not be used again
+		info, err := dbUsers.UpdateAll(bson.M{
+			"_id":         user.ID,
+			"totpCounter": user.TOTPCounter,
+		}, bson.M{"$set": bson.M{"totpCounter": counter}})
+		if err != nil {
+			return CodeError{500, "database error: " + err.Error()}
+		}
+		if info.Updated == 0 {
+			return CodeError{403, "invalid TOTP code"}
+		}
+		return nil
+	}
+
+	hash := hashRecoveryCode(code)
+	for _, h := range user.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			info, err := dbUsers.UpdateAll(bson.M{
+				"_id":           user.ID,
+				"recoveryCodes": hash,
+			}, bson.M{"$pull": bson.M{"recoveryCodes": hash}})
+			if err != nil {
+				return CodeError{500, "database error: " + err.Error()}
+			}
+			if info.Updated == 0 {
+				break
+			}
+			return nil
+		}
+	}
+	return CodeError{403, "invalid TOTP code"}
+}
+
+// DisableTOTP removes the TOTP enrolment and recovery codes of the user.
+func DisableTOTP(userID string) error {
+
+	err := dbUsers.UpdateId(userID, bson.M{
+		"$set": bson.M{"totpEnabled": false},
+		"$unset": bson.M{
+			"totpSecret":    "",
+			"totpCounter":   "",
+			"recoveryCodes": "",
+		},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errUserNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// matchTOTP checks the code against the codes of the current time (± totpSkew periods)
+// that are newer than the last used counter. It returns the counter of the code.
+func matchTOTP(encodedSecret string, code string, lastCounter int64) (int64, bool) {
+
+	secret, err := totpEncoding.DecodeString(encodedSecret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	now := time.Now().Unix() / totpPeriod
+	for c := now - totpSkew; c <= now+totpSkew; c++ {
+		if c <= lastCounter {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, uint64(c))), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package edge
+
+import (
+	"testing"
+	"time"
+)
+
+// The secret of the test vectors of RFC 4226 and RFC 6238 (SHA-1).
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+
+	// RFC 4226 appendix D
+	codes := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range codes {
+		if c := totpCode(rfcSecret, uint64(counter)); c != code {
+			t.Errorf("counter %d: got %s, want %s", counter, c, code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+
+	// RFC 6238 appendix B (SHA-1), the last 6 of the 8 digits
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		if c := totpCode(rfcSecret, uint64(test.time/totpPeriod)); c != test.code {
+			t.Errorf("time %d: got %s, want %s", test.time, c, test.code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+
+	secret := totpEncoding.EncodeToString(rfcSecret)
+	now := time.Now().Unix() / totpPeriod
+
+	if c, ok := matchTOTP(secret, totpCode(rfcSecret, uint64(now)), 0); !ok || c != now {
+		t.Errorf("current code: got %d, %v", c, ok)
+	}
+	if _, ok := matchTOTP(secret, totpCode(rfcSecret, uint64(now-totpSkew)), 0); !ok {
+		t.Errorf("previous code not accepted")
+	}
+	if _, ok := matchTOTP(secret, totpCode(rfcSecret, uint64(now-totpSkew-1)), 0); ok {
+		t.Errorf("old code accepted")
+	}
+	// codes can not be used twice
+	if _, ok := matchTOTP(secret, totpCode(rfcSecret, uint64(now)), now); ok {
+		t.Errorf("used code accepted again")
+	}
+	if _, ok := matchTOTP(secret, "12345", 0); ok {
+		t.Errorf("short code accepted")
+	}
+	if _, ok := matchTOTP("not base32!", totpCode(rfcSecret, uint64(now)), 0); ok {
+		t.Errorf("code accepted for an invalid secret")
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	if hashRecoveryCode("AbCd-1234") != hashRecoveryCode("abcd-1234") {
+		t.Errorf("recovery codes are not case insensitive")
+	}
+	if hashRecoveryCode("abcd-1234") == hashRecoveryCode("abcd-1235") {
+		t.Errorf("different codes have the same hash")
+	}
+}
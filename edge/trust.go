@@ -0,0 +1,144 @@
+package edge
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/globalsign/mgo"
+)
+
+// TrustPolicy decides which requests are accepted without token, and with which role.
+type TrustPolicy struct {
+	// Networks are trusted IP ranges. Requests from there get the role of the first matching network.
+	Networks []TrustedNetwork `json:"networks"`
+	// DockerRole is the role of requests from the wazigate docker network (apps).
+	// Empty to require tokens for apps.
+	DockerRole string `json:"dockerRole"`
+	// Apps override the DockerRole for single apps, identified by their container name
+	// (like "waziup.wazigate-lora"). An empty role requires tokens for that app.
+	Apps map[string]string `json:"apps"`
+}
+
+// TrustedNetwork is a trusted IP range (CIDR notation, like "192.168.1.0/24").
+type TrustedNetwork struct {
+	Name string `json:"name"`
+	CIDR string `json:"cidr"`
+	Role string `json:"role"`
+
+	ipNet *net.IPNet
+}
+
+// DefaultTrustPolicy trusts the gateway itself and all apps as admins.
+var DefaultTrustPolicy = TrustPolicy{
+	Networks: []TrustedNetwork{
+		{Name: "loopback", CIDR: "127.0.0.0/8", Role: RoleAdmin},
+		{Name: "loopback (IPv6)", CIDR: "::1/128", Role: RoleAdmin},
+	},
+	DockerRole: RoleAdmin,
+	Apps:       map[string]string{},
+}
+
+// trustPolicyConfig is the config key to persist the trust policy.
+const trustPolicyConfig = "trustPolicy"
+
+var trustPolicy *TrustPolicy
+var trustPolicyMutex sync.RWMutex
+
+// GetTrustPolicy returns the trust policy, DefaultTrustPolicy if none has been set.
+func GetTrustPolicy() (*TrustPolicy, error) {
+
+	trustPolicyMutex.RLock()
+	policy := trustPolicy
+	trustPolicyMutex.RUnlock()
+	if policy != nil {
+		return policy, nil
+	}
+
+	policy = new(TrustPolicy)
+	value, err := GetConfig(trustPolicyConfig)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			return nil, CodeError{500, "database error: " + err.Error()}
+		}
+		*policy = DefaultTrustPolicy
+	} else if err := json.Unmarshal([]byte(value), policy); err != nil {
+		return nil, CodeError{500, "invalid trust policy: " + err.Error()}
+	}
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+
+	trustPolicyMutex.Lock()
+	trustPolicy = policy
+	trustPolicyMutex.Unlock()
+	return policy, nil
+}
+
+// SetTrustPolicy validates and saves the trust policy.
+func SetTrustPolicy(policy *TrustPolicy) error {
+
+	if err := policy.compile(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return CodeError{500, "can not marshal trust policy: " + err.Error()}
+	}
+	if err := SetConfig(trustPolicyConfig, string(data)); err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+
+	trustPolicyMutex.Lock()
+	trustPolicy = policy
+	trustPolicyMutex.Unlock()
+	return nil
+}
+
+func (policy *TrustPolicy) compile() error {
+	if policy.Networks == nil {
+		policy.Networks = []TrustedNetwork{}
+	}
+	if policy.Apps == nil {
+		policy.Apps = map[string]string{}
+	}
+	for i := range policy.Networks {
+		network := &policy.Networks[i]
+		_, ipNet, err := net.ParseCIDR(network.CIDR)
+		if err != nil {
+			return CodeError{400, "invalid network: " + err.Error()}
+		}
+		if !IsRole(network.Role) {
+			return CodeError{400, "unknown role for network " + network.CIDR + ": " + network.Role}
+		}
+		network.ipNet = ipNet
+	}
+	if policy.DockerRole != "" && !IsRole(policy.DockerRole) {
+		return CodeError{400, "unknown docker role: " + policy.DockerRole}
+	}
+	for app, role := range policy.Apps {
+		if role != "" && !IsRole(role) {
+			return CodeError{400, "unknown role for app " + app + ": " + role}
+		}
+	}
+	return nil
+}
+
+// NetworkRole returns the role of the first trusted network that contains the IP address,
+// or "" if the IP address is not trusted.
+func (policy *TrustPolicy) NetworkRole(ip net.IP) string {
+	for _, network := range policy.Networks {
+		if network.ipNet != nil && network.ipNet.Contains(ip) {
+			return network.Role
+		}
+	}
+	return ""
+}
+
+// AppRole returns the role of requests from that app (container name).
+func (policy *TrustPolicy) AppRole(app string) string {
+	if role, ok := policy.Apps[app]; ok {
+		return role
+	}
+	return policy.DockerRole
+}
@@ -1,8 +1,9 @@
 package edge
 
 import (
-	// "io"
 	// "time"
+	"fmt"
+	"io"
 	"log"
 	"strings"
 
@@ -18,10 +19,62 @@ type User struct {
 	Username    string `json:"username" bson:"username"`
 	Password    string `json:"password" bson:"password"`
 	NewPassword string `json:"newPassword"`
+	// Role is one of RoleAdmin, RoleOperator, RoleViewer or RoleDeviceWriter.
+	// Users without a role (created before roles existed) are admins.
+	Role string `json:"role" bson:"role"`
+	// MustChangePassword is set for the default user (and users with an admin-set password).
+	// All requests except changing the password are rejected while it is set.
+	MustChangePassword bool `json:"mustChangePassword" bson:"mustChangePassword"`
+
+	// TOTPEnabled is set if the user logs in with a TOTP code (or recovery code) as second factor.
+	TOTPEnabled   bool     `json:"totpEnabled" bson:"totpEnabled"`
+	TOTPSecret    string   `json:"-" bson:"totpSecret,omitempty"`
+	TOTPCounter   int64    `json:"-" bson:"totpCounter,omitempty"`
+	RecoveryCodes []string `json:"-" bson:"recoveryCodes,omitempty"`
+
+	// Provider is ProviderOIDC for users of the OpenID Connect login, empty for local users.
+	Provider string `json:"provider,omitempty" bson:"provider,omitempty"`
+	// Subject is the user ID ("sub") at the identity provider.
+	Subject string `json:"-" bson:"subject,omitempty"`
 
 	// LastLogin time.Time `json:"lastlogin" bson:"lastlogin"`
 }
 
+// EffectiveRole returns the role of the user.
+// Users stored before roles existed have no role and are admins.
+func (user *User) EffectiveRole() string {
+	if user.Role == "" {
+		return RoleAdmin
+	}
+	return user.Role
+}
+
+/*--------------------------------*/
+
+// DefaultUsername and DefaultPassword are the credentials of the default user.
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "loragateway"
+)
+
+// PasswordMinLength is the minimum length of new passwords.
+const PasswordMinLength = 8
+
+// CheckPasswordPolicy returns an error if the password must not be used.
+func CheckPasswordPolicy(username string, password string) error {
+
+	if len(password) < PasswordMinLength {
+		return CodeError{400, fmt.Sprintf("the password must have at least %d characters", PasswordMinLength)}
+	}
+	if password == DefaultPassword {
+		return CodeError{400, "the password must not be the default password"}
+	}
+	if strings.EqualFold(password, username) {
+		return CodeError{400, "the password must not be the username"}
+	}
+	return nil
+}
+
 /*--------------------------------*/
 
 // MakeDefaultUser checks if there is no user registered in database,
@@ -37,13 +90,15 @@ func MakeDefaultUser() error {
 	}
 
 	if usersCount > 0 {
-		return nil
+		return checkDefaultPassword()
 	}
 
 	err = AddUser(&User{
-		Name:     "Wazigate User",
-		Username: "admin",
-		Password: "loragateway",
+		Name:               "Wazigate User",
+		Username:           DefaultUsername,
+		Password:           DefaultPassword,
+		Role:               RoleAdmin,
+		MustChangePassword: true,
 	})
 
 	if err != nil {
@@ -55,6 +110,27 @@ func MakeDefaultUser() error {
 	return err
 }
 
+// checkDefaultPassword sets MustChangePassword for the default user
+// of existing gateways if the password has never been changed.
+func checkDefaultPassword() error {
+
+	user, err := FindUserByUsername(DefaultUsername)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	if user.MustChangePassword {
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(DefaultPassword)) != nil {
+		return nil
+	}
+	log.Printf("[WARN ] The user %q still has the default password, it must be changed at the next login.", user.Username)
+	return dbUsers.UpdateId(user.ID, bson.M{"$set": bson.M{"mustChangePassword": true}})
+}
+
 /*--------------------------------*/
 
 // GetUser returns the Wazigate user
@@ -102,6 +178,15 @@ func UpdateUser(userID string, newProfileData *User) error {
 			return CodeError{403, "Wrong password!"}
 		}
 
+		if err := CheckPasswordPolicy(user.Username, newProfileData.NewPassword); err != nil {
+			return err
+		}
+		if newProfileData.NewPassword == newProfileData.Password {
+			return CodeError{400, "the new password must not be the old password"}
+		}
+
+		user.MustChangePassword = false
+
 		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(newProfileData.NewPassword), bcrypt.DefaultCost)
 		if err != nil {
 			log.Printf("[ERR  ] Password Generate: %s", err.Error())
@@ -115,8 +200,9 @@ func UpdateUser(userID string, newProfileData *User) error {
 
 		Update: bson.M{
 			"$set": bson.M{
-				"password": string(hashedPassword),
-				"name":     newProfileData.Name,
+				"password":           string(hashedPassword),
+				"name":               newProfileData.Name,
+				"mustChangePassword": user.MustChangePassword,
 			},
 		},
 	}, &user)
@@ -131,9 +217,19 @@ func UpdateUser(userID string, newProfileData *User) error {
 
 /*--------------------------------*/
 
-// AddUser creates a new user.
+// AddUser creates a new user. The user ID is set on success.
+// Users without role become viewers. Only users stored before roles existed have no role (see EffectiveRole).
 func AddUser(user *User) error {
 
+	if len(user.Username) == 0 { /*We may need to have a policy for username*/
+		return CodeError{400, "username must not be empty"}
+	}
+	if user.Role == "" {
+		user.Role = RoleViewer
+	} else if !IsRole(user.Role) {
+		return CodeError{400, "unknown role: " + user.Role}
+	}
+
 	// Check if the user already exist:
 	_, err := FindUserByUsername(user.Username)
 	if err == nil {
@@ -144,26 +240,132 @@ func AddUser(user *User) error {
 		return CodeError{500, "error: " + err.Error()}
 	}
 
-	//TODO: hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Printf("[ERR  ] Password Generate: %s", err.Error())
+		return CodeError{500, "Internal Error!"}
+	}
+
+	user.ID = bson.NewObjectId().Hex()
+	user.Username = strings.ToLower(user.Username)
+	err = dbUsers.Insert(&User{
+		ID:       user.ID,
+		Name:     user.Name,
+		Username: user.Username,
+		Password: string(hashedPassword),
+		Role:     user.Role,
+
+		MustChangePassword: user.MustChangePassword,
+	})
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+
+	return nil
+}
+
+/*--------------------------------*/
+
+// SetUser changes the name, role or password of a user (as admin).
+// Empty fields are not changed. The password is set from user.NewPassword,
+// together with user.MustChangePassword to force the user to change it.
+func SetUser(userID string, user *User) error {
+
+	old, err := GetUser(userID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errUserNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+
+	set := bson.M{}
+	if user.Name != "" {
+		set["name"] = user.Name
+	}
+	if user.Role != "" && user.Role != old.EffectiveRole() {
+		if !IsRole(user.Role) {
+			return CodeError{400, "unknown role: " + user.Role}
+		}
+		if old.EffectiveRole() == RoleAdmin {
+			if err := checkNotLastAdmin(); err != nil {
+				return err
+			}
+		}
+		set["role"] = user.Role
+	}
+	if user.NewPassword != "" {
+		if err := CheckPasswordPolicy(old.Username, user.NewPassword); err != nil {
+			return err
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("[ERR  ] Password Generate: %s", err.Error())
+			return CodeError{500, "Internal Error!"}
+		}
+		set["password"] = string(hashedPassword)
+		set["mustChangePassword"] = user.MustChangePassword
+	}
+	if len(set) == 0 {
+		return nil
 	}
 
-	if len(user.Username) > 0 { /*We may need to have a policy for username*/
-		dbUsers.Insert(&User{
-			ID:       bson.NewObjectId().Hex(),
-			Name:     user.Name,
-			Username: strings.ToLower(user.Username),
-			Password: string(hashedPassword),
-		})
+	err = dbUsers.UpdateId(userID, bson.M{"$set": set})
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
 	}
+	return nil
+}
 
+var errUserNotFound = CodeError{404, "user not found"}
+
+// checkNotLastAdmin returns an error if there is only one admin left.
+func checkNotLastAdmin() error {
+	// null also matches users without a role
+	n, err := dbUsers.Find(bson.M{
+		"role": bson.M{"$in": []interface{}{RoleAdmin, "", nil}},
+	}).Count()
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	if n <= 1 {
+		return CodeError{409, "the last admin can not be removed"}
+	}
 	return nil
 }
 
 /*--------------------------------*/
 
+// UsersIter iterates over users. Call .Next() to get the next user.
+type UsersIter struct {
+	user   User
+	dbIter *mgo.Iter
+}
+
+// Next returns the next user or nil.
+func (iter *UsersIter) Next() (*User, error) {
+	iter.user = User{}
+	if iter.dbIter.Next(&iter.user) {
+		return &iter.user, iter.dbIter.Err()
+	}
+	return nil, io.EOF
+}
+
+// Close closes the iterator.
+func (iter *UsersIter) Close() error {
+	return iter.dbIter.Close()
+}
+
+// GetUsers returns an iterator over all users.
+func GetUsers() *UsersIter {
+
+	return &UsersIter{
+		dbIter: dbUsers.Find(nil).Sort("username").Iter(),
+	}
+}
+
+/*--------------------------------*/
+
 func CheckUserCredentials(username string, password string) (User, error) {
 
 	user, err := FindUserByUsername(username)
@@ -185,12 +387,27 @@ func CheckUserCredentials(username string, password string) (User, error) {
 
 /*--------------------------------*/
 
-// DeleteUser removes the giveb user.
+// DeleteUser removes the given user.
+// The last admin can not be removed.
 func DeleteUser(userID string) error {
 
-	_, err := dbUsers.RemoveAll(bson.M{
-		"_id": userID,
-	})
+	user, err := GetUser(userID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errUserNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	if user.EffectiveRole() == RoleAdmin {
+		if err := checkNotLastAdmin(); err != nil {
+			return err
+		}
+	}
 
-	return err
+	err = dbUsers.RemoveId(userID)
+	if err != nil {
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	DeleteSessions(userID)
+	return nil
 }
@@ -0,0 +1,143 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// DeviceStatus describes the liveness of a device.
+type DeviceStatus struct {
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"lastSeen"`
+	// ExpectedInterval is the 'expectedInterval' meta in seconds.
+	ExpectedInterval float64   `json:"expectedInterval"`
+	Time             time.Time `json:"time"`
+}
+
+// DeviceStatusCallback is called when a device goes online or offline.
+type DeviceStatusCallback func(deviceID string, status *DeviceStatus)
+
+var deviceStatusCallback DeviceStatusCallback
+
+// OnDeviceStatus sets the global DeviceStatusCallback handler.
+func OnDeviceStatus(cb DeviceStatusCallback) {
+	deviceStatusCallback = cb
+}
+
+// WatchdogInterval is the time between two liveness checks.
+var WatchdogInterval = time.Minute
+
+// deviceOnline holds the last known liveness of all watched devices.
+var deviceOnline = map[string]bool{}
+
+// SetDeviceLastSeen marks the device as seen at that time.
+func SetDeviceLastSeen(deviceID string, t time.Time) error {
+	err := dbDevices.UpdateId(deviceID, bson.M{
+		"$set": bson.M{
+			"lastSeen": t,
+		},
+	})
+
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return ErrNotFound
+		}
+		return CodeError{500, "database error: " + err.Error()}
+	}
+	return nil
+}
+
+// Watchdog checks the liveness of all devices with an 'expectedInterval' meta
+// and expires pending actuator commands every WatchdogInterval.
+// This function will block, so call it with `go Watchdog()`.
+func Watchdog() {
+	for {
+		if err := checkDevices(); err != nil {
+			log.Printf("[ERR  ] Watchdog: %v", err)
+		}
+		expireCommands()
+		time.Sleep(WatchdogInterval)
+	}
+}
+
+func checkDevices() error {
+
+	iter := dbDevices.Find(bson.M{
+		"meta.expectedInterval": bson.M{"$exists": true},
+	}).Select(bson.M{
+		"name":     1,
+		"lastSeen": 1,
+		"meta":     1,
+	}).Iter()
+
+	now := time.Now()
+	watched := make(map[string]struct{})
+
+	var device Device
+	for iter.Next(&device) {
+		interval := device.Meta.ExpectedInterval()
+		if interval == 0 {
+			continue
+		}
+		watched[device.ID] = struct{}{}
+		online := device.LastSeen != nil && now.Sub(*device.LastSeen) <= interval
+
+		wasOnline, known := deviceOnline[device.ID]
+		deviceOnline[device.ID] = online
+
+		// Devices are expected to be online, so devices that are offline
+		// at their first check (like after a restart) get an alert, too.
+		if (known && wasOnline != online) || (!known && !online) {
+			onDeviceStatus(&device, &DeviceStatus{
+				Online:           online,
+				LastSeen:         device.LastSeen,
+				ExpectedInterval: interval.Seconds(),
+				Time:             now,
+			})
+		}
+		device = Device{}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	for deviceID := range deviceOnline {
+		if _, ok := watched[deviceID]; !ok {
+			delete(deviceOnline, deviceID)
+		}
+	}
+	return nil
+}
+
+func onDeviceStatus(device *Device, status *DeviceStatus) {
+
+	msg := Message{
+		Target: "devices/" + device.ID,
+	}
+	if status.Online {
+		log.Printf("[INFO ] Device %s is back online.", device.ID)
+		msg.Title = "Device online"
+		msg.Severity = "info"
+		msg.Text = fmt.Sprintf("The device %q (%s) is sending data again.", device.Name, device.ID)
+	} else {
+		log.Printf("[WARN ] Device %s is offline.", device.ID)
+		msg.Title = "Device offline"
+		msg.Severity = "warning"
+		if status.LastSeen == nil {
+			msg.Text = fmt.Sprintf("The device %q (%s) has never sent any data.", device.Name, device.ID)
+		} else {
+			msg.Text = fmt.Sprintf("The device %q (%s) has not sent any data since %s (expected every %s).", device.Name, device.ID, status.LastSeen.Format(time.RFC3339), device.Meta.ExpectedInterval())
+		}
+	}
+	if err := PostMessage(&msg); err != nil {
+		log.Printf("[ERR  ] Watchdog: %v", err)
+	}
+
+	if deviceStatusCallback != nil {
+		deviceStatusCallback(device.ID, status)
+	}
+}
@@ -50,6 +50,12 @@ type wsWrapper struct {
 	version byte
 	timeout time.Duration
 	heap    []byte
+	// login is the login of the upgrade request, used if the CONNECT packet has no credentials.
+	login *api.MQTTLogin
+}
+
+func (w *wsWrapper) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
 }
 
 var errTextMsg = errors.New("unexpected TEXT message")
@@ -179,7 +185,7 @@ func serveHTTPUpgrade(resp http.ResponseWriter, req *http.Request, params httpro
 		return
 	}
 
-	wrapper := &wsWrapper{conn: conn}
+	wrapper := &wsWrapper{conn: conn, login: api.HTTPLogin(req)}
 	mqttServer.Serve(wrapper)
 }
 
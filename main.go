@@ -19,8 +19,11 @@ import (
 	"github.com/Waziup/wazigate-edge/api"
 	"github.com/Waziup/wazigate-edge/clouds"
 	"github.com/Waziup/wazigate-edge/edge"
+	_ "github.com/Waziup/wazigate-edge/edge/codecs/cbor"
 	_ "github.com/Waziup/wazigate-edge/edge/codecs/javascript"
 	_ "github.com/Waziup/wazigate-edge/edge/codecs/json"
+	_ "github.com/Waziup/wazigate-edge/edge/codecs/lpp"
+	_ "github.com/Waziup/wazigate-edge/edge/codecs/senml"
 	_ "github.com/Waziup/wazigate-edge/edge/codecs/xlpp"
 	"github.com/Waziup/wazigate-edge/mqtt"
 	"github.com/Waziup/wazigate-edge/tools"
@@ -154,6 +157,12 @@ func main() {
 	// Creating the default user in db if there is no user.
 	edge.MakeDefaultUser()
 
+	// Loading (or creating) the JWT signing keys, so logins survive restarts.
+	if err := edge.RotateSigningKeys(); err != nil {
+		log.Fatalf("[ERR  ] Setup failed: %v", err)
+	}
+	go edge.SigningKeysRotator()
+
 	// user, _ := edge.FindUserByUsername( "admin")
 	// log.Printf("User %q.\n", user)
 	// edge.DeleteUser( user.ID)
@@ -175,6 +184,10 @@ func main() {
 		log.Fatalf("[ERR  ] Setup failed: %v.", err)
 	}
 
+	edge.OnDeviceStatus(deviceStatusCallback)
+	edge.OnCommand(commandCallback)
+	go edge.Watchdog()
+
 	////////////////////
 
 	if *tlsCert != "" && *tlsKey != "" {
@@ -322,6 +335,14 @@ func Serve(_resp http.ResponseWriter, _req *http.Request) int {
 		req.RequestURI,
 		size)
 
+	if req.Method == MethodPublish || req.Method == http.MethodPut || req.Method == http.MethodPost || req.Method == http.MethodDelete {
+		auditBody := body
+		if replacedBody != nil {
+			auditBody, _ = json.Marshal(replacedBody)
+		}
+		api.Audit(req, resp.status, auditBody)
+	}
+
 	if req.Method == MethodPublish || req.Method == http.MethodPut || req.Method == http.MethodPost {
 		if resp.status >= 200 && resp.status < 300 {
 			msg := mqtt.Message{
@@ -449,6 +470,22 @@ func eventCallback(cloud *clouds.Cloud, event clouds.Event) {
 	})
 }
 
+func deviceStatusCallback(deviceID string, status *edge.DeviceStatus) {
+	data, _ := json.Marshal(status)
+	mqttServer.Publish(nil, &mqtt.Message{
+		Topic: "devices/" + deviceID + "/status",
+		Data:  data,
+	})
+}
+
+func commandCallback(cmd *edge.Command) {
+	data, _ := json.Marshal(cmd)
+	mqttServer.Publish(nil, &mqtt.Message{
+		Topic: "devices/" + cmd.DeviceID + "/actuators/" + cmd.ActuatorID + "/commands/" + cmd.ID.Hex(),
+		Data:  data,
+	})
+}
+
 func dialServerUnix(addr string) func(_ *mgo.ServerAddr) (net.Conn, error) {
 	return func(_ *mgo.ServerAddr) (net.Conn, error) {
 		return net.Dial("unix", addr)
@@ -10,6 +10,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Waziup/wazigate-edge/api"
+	"github.com/Waziup/wazigate-edge/clouds"
 	"github.com/Waziup/wazigate-edge/mqtt"
 	"github.com/Waziup/wazigate-edge/tools"
 )
@@ -23,21 +25,32 @@ var MethodPublish = "PUBLISH"
 func mqttAuth(client *mqtt.Client, auth *mqtt.ConnectAuth) mqtt.ConnectCode {
 	client.Server = mqttServer
 
-	// if auth == nil {
-	// 	return mqtt.CodeNotAuthorized
-	// }
-
-	// var err error
-	// if auth.Username == "" {
-	// 	_, err = api.CheckToken(auth.Password)
-	// } else {
-	// 	_, err = edge.CheckUserCredentials(auth.Username, auth.Password)
-	// }
-	// if err != nil {
-	// 	log.Printf("[MQTT ] Login failed: %v", err)
-	// 	return mqtt.CodeBatUserOrPassword
-	// }
+	var username, password string
+	if auth != nil {
+		username, password = auth.Username, auth.Password
+	}
+
+	if username == "" && password == "" {
+		// MQTT over WebSocket uses the login of the (authorized) upgrade request
+		if ws, ok := client.Stream().(*wsWrapper); ok && ws.login != nil {
+			client.Login = ws.login
+			return mqtt.CodeAccepted
+		}
+	}
 
+	var addr net.Addr
+	if s, ok := client.Stream().(interface{ RemoteAddr() net.Addr }); ok {
+		addr = s.RemoteAddr()
+	}
+	login, err := api.MQTTConnect(username, password, addr)
+	if err != nil {
+		log.Printf("[MQTT ] Login of %q failed: %v", client.ID(), err)
+		if username == "" && password == "" {
+			return mqtt.CodeNotAuthorized
+		}
+		return mqtt.CodeBatUserOrPassword
+	}
+	client.Login = login
 	return mqtt.CodeAccepted
 }
 
@@ -81,14 +94,62 @@ func (server *MQTTServer) Publish(sender mqtt.Sender, msg *mqtt.Message) int {
 		RemoteAddr:    sender.ID(),
 		RequestURI:    uri,
 	}
+	var login *api.MQTTLogin
+	if client, ok := sender.(*mqtt.Client); ok {
+		login, _ = client.Login.(*api.MQTTLogin)
+	} else if cloudID := clouds.SenderCloudID(sender); cloudID != "" {
+		login = &api.MQTTLogin{CloudID: cloudID}
+	}
 	resp := MQTTResponse{
 		status: 200,
 		header: make(http.Header),
 	}
-	hit := Serve(&resp, &req)
+	hit := Serve(&resp, api.WithMQTTLogin(&req, login))
 	return hit
 }
 
+// Subscribe rejects subscriptions of clients without the read permission for the topic.
+func (server *MQTTServer) Subscribe(recv mqtt.Reciever, topic string, qos byte) *mqtt.Subscription {
+
+	if client, ok := recv.(*mqtt.Client); ok && !server.canSubscribe(client, topic) {
+		return nil
+	}
+	return server.Server.Subscribe(recv, topic, qos)
+}
+
+// SubscribeAll rejects subscriptions of clients without the read permission for the topics.
+func (server *MQTTServer) SubscribeAll(recv mqtt.Reciever, topics []mqtt.TopicSubscription) []*mqtt.Subscription {
+
+	client, ok := recv.(*mqtt.Client)
+	if !ok {
+		return server.Server.SubscribeAll(recv, topics)
+	}
+	allowed := make([]mqtt.TopicSubscription, 0, len(topics))
+	for _, topic := range topics {
+		if server.canSubscribe(client, topic.Name) {
+			allowed = append(allowed, topic)
+		}
+	}
+	subs := server.Server.SubscribeAll(recv, allowed)
+	all := make([]*mqtt.Subscription, len(topics))
+	for i, j := 0, 0; i < len(topics) && j < len(allowed); i++ {
+		if topics[i] == allowed[j] {
+			all[i] = subs[j]
+			j++
+		}
+	}
+	return all
+}
+
+func (server *MQTTServer) canSubscribe(client *mqtt.Client, topic string) bool {
+	login, _ := client.Login.(*api.MQTTLogin)
+	if !api.MQTTSubscribeAllowed(login, topic) {
+		log.Printf("[MQTT ] Subscription of %q to %q rejected.", client.ID(), topic)
+		return false
+	}
+	return true
+}
+
 /*
 
 func (server *MQTTServer) Connect(client *mqtt.Client, auth *mqtt.ConnectAuth) mqtt.ConnectCode {
@@ -51,6 +51,9 @@ type Client struct {
 	Server Server
 	// session *Session
 
+	// Login is set by the Authenticate function of the server, like the user of the client.
+	Login interface{}
+
 	will *Message
 
 	counter int
@@ -62,6 +65,11 @@ func (client *Client) ID() string {
 	return client.id
 }
 
+// Stream returns the stream of the client connection.
+func (client *Client) Stream() Stream {
+	return client.stream
+}
+
 func (client *Client) Will() *Message {
 	return client.will
 }
@@ -261,6 +269,11 @@ func (client *Client) Subscribe(topic string, qos byte) (byte, error) {
 		client.Server.Unsubscribe(subs)
 	}
 	subs = client.Server.Subscribe(client, topic, qos)
+	if subs == nil {
+		// the server rejected the subscription
+		delete(client.subscriptions, topic)
+		return byte(Failure), nil
+	}
 	client.subscriptions[topic] = subs
 	return subs.qos, nil
 }
@@ -293,6 +306,12 @@ func (client *Client) SubscribeAll(topics []TopicSubscription) ([]byte, error) {
 	subs := client.Server.SubscribeAll(client, topics)
 	granted := make([]byte, len(topics))
 	for i, topic := range topics {
+		if subs[i] == nil {
+			// the server rejected the subscription
+			delete(client.subscriptions, topic.Name)
+			granted[i] = byte(Failure)
+			continue
+		}
 		client.subscriptions[topic.Name] = subs[i]
 		granted[i] = subs[i].qos
 	}
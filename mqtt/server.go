@@ -56,8 +56,10 @@ type Server interface {
 	// Publish can be called from clients to emit new messages.
 	Publish(sender Sender, msg *Message) int
 	// Subscribe adds a new subscription to the topics tree.
+	// It returns nil if the subscription is rejected.
 	Subscribe(recv Reciever, topic string, qos byte) *Subscription
 	// SubscribeAll adds a list of subscriptions to the topics tree.
+	// Rejected subscriptions are nil.
 	SubscribeAll(recv Reciever, topics []TopicSubscription) []*Subscription
 	// Unsubscribe releases a subscription.
 	Unsubscribe(subs ...*Subscription)
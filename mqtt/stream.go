@@ -2,6 +2,7 @@ package mqtt
 
 import (
 	"io"
+	"net"
 	"time"
 )
 
@@ -30,6 +31,14 @@ func NewStream(conn Conn, tout time.Duration) Stream {
 	return &stream{conn, tout, 0}
 }
 
+// RemoteAddr returns the remote address of the connection, or nil.
+func (s *stream) RemoteAddr() net.Addr {
+	if conn, ok := s.conn.(net.Conn); ok {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
+
 func (s *stream) ReadPacket() (pkt Packet, err error) {
 	if s.timeout != 0 {
 		s.conn.SetReadDeadline(time.Now().Add(s.timeout))